@@ -0,0 +1,108 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AnnotationMatcher requires a PVC annotation to be present
+type AnnotationMatcher struct {
+	// Key of the annotation
+	Key string `json:"key"`
+
+	// Value of the annotation, any value of the key is matched when empty
+	// +optional
+	Value string `json:"value,omitempty"`
+}
+
+// ReleasePolicySpec defines which PVCs may be released upon a node termination and how
+type ReleasePolicySpec struct {
+	// NamespaceSelector selects the namespaces of the covered PVCs, all namespaces are covered when empty
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// PVCSelector selects the covered PVCs by their labels, all PVCs are covered when empty
+	// +optional
+	PVCSelector *metav1.LabelSelector `json:"pvcSelector,omitempty"`
+
+	// AnnotationMatchers lists annotations all of which the covered PVCs must carry
+	// +optional
+	AnnotationMatchers []AnnotationMatcher `json:"annotationMatchers,omitempty"`
+
+	// StorageClasses is an allowlist of the covered PVC storage class names, all storage classes are covered when empty
+	// +optional
+	StorageClasses []string `json:"storageClasses,omitempty"`
+
+	// Delay postpones the release of the covered PVCs, counted from the node termination
+	// +optional
+	Delay *metav1.Duration `json:"delay,omitempty"`
+
+	// DryRun reports the covered PVCs without releasing them
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// MaxReleasesPerNode limits the number of covered PVCs released per terminated node, unlimited when unset
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxReleasesPerNode *int32 `json:"maxReleasesPerNode,omitempty"`
+}
+
+// ReleasePolicyStatus defines the observed state of ReleasePolicy
+type ReleasePolicyStatus struct {
+	// MatchedPVCs is the number of PVCs on terminated nodes that were matched by the policy
+	// +optional
+	MatchedPVCs int64 `json:"matchedPVCs,omitempty"`
+
+	// ReleasedPVCs is the number of PVCs released by the policy
+	// +optional
+	ReleasedPVCs int64 `json:"releasedPVCs,omitempty"`
+
+	// LastReleaseTime is the last time a PVC was released by the policy
+	// +optional
+	LastReleaseTime *metav1.Time `json:"lastReleaseTime,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:printcolumn:name="Dry-Run",type=boolean,JSONPath=`.spec.dryRun`
+//+kubebuilder:printcolumn:name="Matched",type=integer,JSONPath=`.status.matchedPVCs`
+//+kubebuilder:printcolumn:name="Released",type=integer,JSONPath=`.status.releasedPVCs`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ReleasePolicy is the Schema for the releasepolicies API
+type ReleasePolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ReleasePolicySpec   `json:"spec,omitempty"`
+	Status ReleasePolicyStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ReleasePolicyList contains a list of ReleasePolicy
+type ReleasePolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ReleasePolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ReleasePolicy{}, &ReleasePolicyList{})
+}
@@ -19,10 +19,13 @@ package main
 import (
 	"flag"
 	"os"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -34,9 +37,13 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 
+	releaserv1alpha1 "github.com/AppsFlyer/local-pvc-releaser/api/v1alpha1"
+	"github.com/AppsFlyer/local-pvc-releaser/internal/breaker"
+	"github.com/AppsFlyer/local-pvc-releaser/internal/classifier"
 	"github.com/AppsFlyer/local-pvc-releaser/internal/controller"
 	"github.com/AppsFlyer/local-pvc-releaser/internal/exporters"
 	"github.com/AppsFlyer/local-pvc-releaser/internal/initializers"
+	"github.com/AppsFlyer/local-pvc-releaser/internal/triggers"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -48,6 +55,8 @@ var (
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
+	utilruntime.Must(releaserv1alpha1.AddToScheme(scheme))
+
 	//+kubebuilder:scaffold:scheme
 }
 
@@ -60,6 +69,39 @@ func main() {
 	var pvcSelector bool
 	var pvcAnoCustomKey string
 	var pvcAnoCustomValue string
+	var triggerRulesFile string
+	var triggerRules triggers.RuleFlag
+	var enableOrphanSweep bool
+	var orphanSweepInterval time.Duration
+	var orphanSweepDryRun bool
+	var releaseDelay time.Duration
+	var localCSIDrivers string
+	var localTopologyKeys string
+	var pvNodeAffinityDiscovery bool
+	var pvNodeTopologyKey string
+	var enableCircuitBreaker bool
+	var circuitBreakerConfigMap string
+	var controllerNamespace string
+	var blastRadius breaker.Limits
+	var maxConcurrentStatefulSetReleases int
+	var statefulSetRecoveryTimeout time.Duration
+	var forceDeletePods bool
+	var forceDeletePodsDryRun bool
+	var enableRecoveryTracking bool
+	var recoveryStallThreshold time.Duration
+	var enableInventoryMetrics bool
+	var maxEventAge time.Duration
+	var persistProcessedEvents bool
+	var processedEventsConfigMap string
+	var eventNamespace string
+	var missingPVPolicy string
+	var unboundPVCAction string
+	var enablePVJanitor bool
+	var cleanVolumeAttachments bool
+	var reprovisionStandalonePVCs bool
+	var reprovisionConfigMap string
+	var pvJanitorAction string
+	var transformVolumeCache bool
 
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
@@ -71,6 +113,42 @@ func main() {
 	flag.BoolVar(&pvcSelector, "enable-pvc-selector", false, "Manage only PVC objects marked with custom annotation.")
 	flag.StringVar(&pvcAnoCustomKey, "pvc-annotation-custom-key", "appsflyer.com/local-pvc-releaser", "PVC Annotations filter key.")
 	flag.StringVar(&pvcAnoCustomValue, "pvc-annotation-custom-value", "enabled", "PVC Annotations filter value.")
+	flag.StringVar(&triggerRulesFile, "trigger-rules-file", "", "Path to a YAML/JSON file with the event trigger rules.")
+	flag.Var(&triggerRules, "trigger-rule", "Event trigger rule in the form of reason=<reason>,source=<component>,kind=<kind>,message=<regex>,nodeField=<name|namespace|fieldPath>. Can be repeated, replaces the default RemovingNode rule.")
+	flag.BoolVar(&enableOrphanSweep, "enable-orphan-sweep", false, "Release local PVCs pinned to nonexistent nodes on startup and periodically.")
+	flag.DurationVar(&orphanSweepInterval, "orphan-sweep-interval", 10*time.Minute, "Interval between orphan PVC sweeps, 0 runs the sweep on startup only.")
+	flag.BoolVar(&orphanSweepDryRun, "orphan-sweep-dry-run", false, "Only report the orphan PVCs found by the sweeper without releasing them.")
+	flag.DurationVar(&releaseDelay, "release-delay", 0, "Grace period between the node termination and the PVC release, cancelled if the node comes back.")
+	flag.StringVar(&localCSIDrivers, "local-csi-drivers", "", "Comma separated list of CSI drivers provisioning node-local volumes (e.g. topolvm.io,local.csi.openebs.io).")
+	flag.StringVar(&localTopologyKeys, "local-topology-keys", "", "Comma separated list of topology keys, a PV pinned by its node affinity to a single value of one of them is node-local (e.g. kubernetes.io/hostname).")
+	flag.BoolVar(&pvNodeAffinityDiscovery, "enable-pv-node-affinity-discovery", false, "Find PVCs through the node affinity of their PVs as well, for statically provisioned local PVs.")
+	flag.StringVar(&pvNodeTopologyKey, "pv-node-topology-key", classifier.HostnameTopologyKey, "Node label key whose value, as required by the PV node affinity, is the node name.")
+	flag.BoolVar(&enableCircuitBreaker, "enable-circuit-breaker", false, "Stop all PVC deletions once one of the blast radius limits is exceeded, until an operator resets the circuit breaker.")
+	flag.IntVar(&blastRadius.MaxPVCsPerMinute, "max-pvc-releases-per-minute", 0, "Maximum number of PVCs released per minute across the cluster, 0 is unlimited.")
+	flag.IntVar(&blastRadius.MaxNamespacePVCsPerMinute, "max-namespace-pvc-releases-per-minute", 0, "Maximum number of PVCs released per minute in a single namespace, 0 is unlimited.")
+	flag.IntVar(&blastRadius.MaxNodesPerWindow, "max-nodes-per-window", 0, "Maximum number of terminated nodes processed during the node window, 0 is unlimited.")
+	flag.DurationVar(&blastRadius.NodeWindow, "node-window", 10*time.Minute, "Sliding window of the max-nodes-per-window limit.")
+	flag.StringVar(&circuitBreakerConfigMap, "circuit-breaker-configmap", "local-pvc-releaser-circuit-breaker", "Name of the ConfigMap holding the circuit breaker state.")
+	flag.StringVar(&controllerNamespace, "controller-namespace", os.Getenv("POD_NAMESPACE"), "Namespace of the circuit breaker ConfigMap, defaults to the POD_NAMESPACE environment variable.")
+	flag.IntVar(&maxConcurrentStatefulSetReleases, "max-concurrent-releases-per-statefulset", 0, "Maximum number of replicas of a StatefulSet recovering from a released PVC at the same time, 0 is unlimited.")
+	flag.DurationVar(&statefulSetRecoveryTimeout, "statefulset-recovery-timeout", 30*time.Minute, "Time after which a StatefulSet replica that did not recover no longer holds the releases of further replicas, 0 never expires.")
+	flag.BoolVar(&forceDeletePods, "force-delete-pods", false, "Force delete the pods left on a removed node that reference a released PVC, so pvc-protection does not block its deletion.")
+	flag.BoolVar(&forceDeletePodsDryRun, "force-delete-pods-dry-run", false, "Only report the pods that would have been force deleted.")
+	flag.BoolVar(&enableRecoveryTracking, "enable-recovery-tracking", false, "Follow the released PVCs until their workload recovered and export the recovery time.")
+	flag.DurationVar(&recoveryStallThreshold, "recovery-stall-threshold", 30*time.Minute, "Time after the release in which a workload that did not recover raises a warning event, 0 disables the warning.")
+	flag.BoolVar(&enableInventoryMetrics, "enable-inventory-metrics", false, "Export the node-local PVCs and their requested capacity per node, namespace and storage class.")
+	flag.DurationVar(&maxEventAge, "max-event-age", 0, "Ignore node termination events older than this age, such as the ones replayed on restart, 0 is unlimited.")
+	flag.BoolVar(&persistProcessedEvents, "persist-processed-events", false, "Persist the processed node terminations in a ConfigMap, so each one is handled once across restarts and replicas.")
+	flag.StringVar(&processedEventsConfigMap, "processed-events-configmap", "local-pvc-releaser-processed-events", "Name of the ConfigMap holding the processed node terminations.")
+	flag.StringVar(&eventNamespace, "event-namespace", "", "Cache and watch the node termination events of this namespace only, empty watches all the namespaces.")
+	flag.BoolVar(&transformVolumeCache, "transform-volume-cache", false, "Cache the PVCs and PVs without their managed fields and last-applied configuration to reduce memory.")
+	flag.StringVar(&missingPVPolicy, "missing-pv-policy", controller.MissingPVPolicySkip, "Handling of the PVCs whose bound PV no longer exists, either 'skip' or 'delete'.")
+	flag.StringVar(&unboundPVCAction, "unbound-pvc-action", controller.UnboundPVCActionReschedule, "Handling of the unbound PVCs whose selected node is gone, either 'reschedule', 'delete' or 'skip'.")
+	flag.BoolVar(&enablePVJanitor, "enable-pv-janitor", false, "Clean up the node-local PVs of the released PVCs once their claim is gone and their node no longer exists.")
+	flag.StringVar(&pvJanitorAction, "pv-janitor-action", controller.PVJanitorActionDelete, "Action of the PV janitor on an orphaned PV, either 'delete' or 'recycle' (strip its ClaimRef).")
+	flag.BoolVar(&cleanVolumeAttachments, "clean-volume-attachments", false, "Remove the finalizers of the VolumeAttachments of a released PVC volume on the removed node and delete them.")
+	flag.BoolVar(&reprovisionStandalonePVCs, "reprovision-standalone-pvcs", false, "Re-create the released PVCs not owned by a StatefulSet without their volume, so the same pod spec can bind again.")
+	flag.StringVar(&reprovisionConfigMap, "reprovision-configmap", "local-pvc-releaser-reprovisioned-pvcs", "Name of the ConfigMap holding the snapshots of the PVCs to re-create.")
 	flag.Parse()
 
 	logger, err := initializers.NewLogger(devLogging, dryrun)
@@ -84,6 +162,25 @@ func main() {
 
 	ctrl.SetLogger(*logger)
 
+	if missingPVPolicy != controller.MissingPVPolicySkip && missingPVPolicy != controller.MissingPVPolicyDelete {
+		setupLog.Error(nil, "unknown --missing-pv-policy, expected 'skip' or 'delete'", "policy", missingPVPolicy)
+		os.Exit(1)
+	}
+	if unboundPVCAction != controller.UnboundPVCActionReschedule && unboundPVCAction != controller.UnboundPVCActionDelete && unboundPVCAction != controller.UnboundPVCActionSkip {
+		setupLog.Error(nil, "unknown --unbound-pvc-action, expected 'reschedule', 'delete' or 'skip'", "action", unboundPVCAction)
+		os.Exit(1)
+	}
+	if pvJanitorAction != controller.PVJanitorActionDelete && pvJanitorAction != controller.PVJanitorActionRecycle {
+		setupLog.Error(nil, "unknown --pv-janitor-action, expected 'delete' or 'recycle'", "action", pvJanitorAction)
+		os.Exit(1)
+	}
+
+	triggerRuleSet, err := triggers.Load(triggerRulesFile, triggerRules)
+	if err != nil {
+		setupLog.Error(err, "failed to load trigger rules")
+		os.Exit(1)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
 		Metrics:                server.Options{BindAddress: metricsAddr},
@@ -92,6 +189,7 @@ func main() {
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "ab49af34.appsflyer.com",
 		Client:                 client.Options{DryRun: &dryrun},
+		Cache:                  cache.Options{ByObject: controller.CacheByObject(triggerRuleSet, eventNamespace, transformVolumeCache)},
 		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
 		// when the Manager ends. This requires the binary to immediately end when the
 		// Manager is stopped, otherwise, this setting is unsafe. Setting this significantly
@@ -114,20 +212,117 @@ func main() {
 	collector := exporters.NewCollector()
 	metrics.Registry.MustRegister(collector)
 
-	if err = (&controller.PVCReconciler{
-		Client:            mgr.GetClient(),
-		Scheme:            mgr.GetScheme(),
-		Recorder:          mgr.GetEventRecorderFor("local-pvc-releaser"),
-		DryRun:            dryrun,
-		PvcSelector:       pvcSelector,
-		PvcAnoCustomKey:   pvcAnoCustomKey,
-		PvcAnoCustomValue: pvcAnoCustomValue,
-		Logger:            logger,
-		Collector:         collector,
-	}).SetupWithManager(mgr); err != nil {
+	pvcReconciler := &controller.PVCReconciler{
+		Client:                  mgr.GetClient(),
+		Scheme:                  mgr.GetScheme(),
+		Recorder:                mgr.GetEventRecorderFor("local-pvc-releaser"),
+		DryRun:                  dryrun,
+		PvcSelector:             pvcSelector,
+		PvcAnoCustomKey:         pvcAnoCustomKey,
+		PvcAnoCustomValue:       pvcAnoCustomValue,
+		Logger:                  logger,
+		Collector:               collector,
+		Triggers:                triggerRuleSet,
+		ReleaseDelay:            releaseDelay,
+		Classifier:              classifier.New(splitList(localCSIDrivers), splitList(localTopologyKeys)),
+		PVNodeAffinityDiscovery: pvNodeAffinityDiscovery,
+		NodeTopologyKey:         pvNodeTopologyKey,
+
+		MaxConcurrentStatefulSetReleases: maxConcurrentStatefulSetReleases,
+		StatefulSetRecoveryTimeout:       statefulSetRecoveryTimeout,
+		ForceDeletePods:                  forceDeletePods,
+		ForceDeletePodsDryRun:            forceDeletePodsDryRun,
+		CleanVolumeAttachments:           cleanVolumeAttachments,
+		MaxEventAge:                      maxEventAge,
+		MissingPVPolicy:                  missingPVPolicy,
+		UnboundPVCAction:                 unboundPVCAction,
+	}
+	if persistProcessedEvents {
+		pvcReconciler.ProcessedEvents = &controller.ProcessedEvents{
+			Client:    mgr.GetClient(),
+			Reader:    mgr.GetAPIReader(),
+			Namespace: controllerNamespace,
+			Name:      processedEventsConfigMap,
+		}
+	}
+	if (enableCircuitBreaker || persistProcessedEvents || reprovisionStandalonePVCs) && controllerNamespace == "" {
+		setupLog.Error(nil, "the circuit breaker, the processed events persistence and the pvc reprovisioning require --controller-namespace or the POD_NAMESPACE environment variable")
+		os.Exit(1)
+	}
+	if enableCircuitBreaker {
+		pvcReconciler.Breaker = &controller.CircuitBreaker{
+			Client:    mgr.GetClient(),
+			Reader:    mgr.GetAPIReader(),
+			Namespace: controllerNamespace,
+			Name:      circuitBreakerConfigMap,
+			Limiter:   breaker.NewLimiter(blastRadius),
+			Recorder:  pvcReconciler.Recorder,
+			Collector: collector,
+			Logger:    logger,
+		}
+	}
+	if enableRecoveryTracking {
+		pvcReconciler.Recovery = &controller.RecoveryTracker{
+			Client:         mgr.GetClient(),
+			Recorder:       pvcReconciler.Recorder,
+			Collector:      collector,
+			Logger:         logger,
+			StallThreshold: recoveryStallThreshold,
+		}
+		if err = mgr.Add(pvcReconciler.Recovery); err != nil {
+			setupLog.Error(err, "unable to add recovery tracker")
+			os.Exit(1)
+		}
+	}
+	if reprovisionStandalonePVCs {
+		pvcReconciler.Reprovisioner = &controller.PVCReprovisioner{
+			Client:    mgr.GetClient(),
+			Reader:    mgr.GetAPIReader(),
+			Namespace: controllerNamespace,
+			Name:      reprovisionConfigMap,
+			Recorder:  pvcReconciler.Recorder,
+			Collector: collector,
+			Logger:    logger,
+		}
+		if err = mgr.Add(pvcReconciler.Reprovisioner); err != nil {
+			setupLog.Error(err, "unable to add pvc reprovisioner")
+			os.Exit(1)
+		}
+	}
+	if enablePVJanitor {
+		pvcReconciler.PVJanitor = &controller.PVJanitor{
+			Client:          mgr.GetClient(),
+			Recorder:        pvcReconciler.Recorder,
+			Collector:       collector,
+			Logger:          logger,
+			Action:          pvJanitorAction,
+			NodeTopologyKey: pvNodeTopologyKey,
+			DryRun:          dryrun,
+		}
+		if err = mgr.Add(pvcReconciler.PVJanitor); err != nil {
+			setupLog.Error(err, "unable to add pv janitor")
+			os.Exit(1)
+		}
+	}
+	if err = pvcReconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "PersistentVolumeClaim")
 		os.Exit(1)
 	}
+
+	if enableInventoryMetrics {
+		metrics.Registry.MustRegister(exporters.NewInventoryCollector(mgr.GetClient(), pvcReconciler.LocatePVC, logger))
+	}
+
+	if enableOrphanSweep {
+		if err = mgr.Add(&controller.OrphanSweeper{
+			Reconciler: pvcReconciler,
+			Interval:   orphanSweepInterval,
+			DryRun:     orphanSweepDryRun,
+		}); err != nil {
+			setupLog.Error(err, "unable to add orphan sweeper")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -145,3 +340,15 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// splitList splits a comma separated flag value, ignoring empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
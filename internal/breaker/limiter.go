@@ -0,0 +1,123 @@
+package breaker
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	ReasonGlobalRate    = "global-pvc-rate"
+	ReasonNamespaceRate = "namespace-pvc-rate"
+	ReasonNodeRate      = "node-rate"
+
+	pvcWindow = time.Minute
+)
+
+// Limits defines the blast radius of the controller, a zero limit is unlimited
+type Limits struct {
+	MaxPVCsPerMinute          int
+	MaxNamespacePVCsPerMinute int
+	MaxNodesPerWindow         int
+	NodeWindow                time.Duration
+}
+
+type release struct {
+	namespace string
+	time      time.Time
+}
+
+// Limiter counts the PVC releases and the processed nodes on sliding windows and reports once a limit would be exceeded
+type Limiter struct {
+	mu       sync.Mutex
+	limits   Limits
+	releases []release
+	nodes    map[string]time.Time
+	now      func() time.Time
+}
+
+func NewLimiter(limits Limits) *Limiter {
+	return &Limiter{
+		limits: limits,
+		nodes:  make(map[string]time.Time),
+		now:    time.Now,
+	}
+}
+
+// AdmitPVC reports whether one more PVC release in the given namespace stays within the PVC rate limits.
+// The exceeded limit is returned when the release is not admitted. An admitted release counts only once recorded by RecordPVC.
+func (l *Limiter) AdmitPVC(namespace string) (string, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.pruneReleases(l.now())
+
+	if l.limits.MaxPVCsPerMinute > 0 && len(l.releases)+1 > l.limits.MaxPVCsPerMinute {
+		return ReasonGlobalRate, false
+	}
+
+	if l.limits.MaxNamespacePVCsPerMinute > 0 {
+		count := 0
+		for _, r := range l.releases {
+			if r.namespace == namespace {
+				count++
+			}
+		}
+		if count+1 > l.limits.MaxNamespacePVCsPerMinute {
+			return ReasonNamespaceRate, false
+		}
+	}
+
+	return "", true
+}
+
+// RecordPVC records a completed PVC release in the given namespace
+func (l *Limiter) RecordPVC(namespace string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.pruneReleases(now)
+	l.releases = append(l.releases, release{namespace: namespace, time: now})
+}
+
+// AdmitNode records the processing of a terminated node, unless it exceeds the nodes limit of the window.
+// A node that was already admitted during the window is admitted again.
+func (l *Limiter) AdmitNode(nodeName string) (string, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	for name, admitted := range l.nodes {
+		if now.Sub(admitted) > l.limits.NodeWindow {
+			delete(l.nodes, name)
+		}
+	}
+
+	if _, exists := l.nodes[nodeName]; exists {
+		return "", true
+	}
+
+	if l.limits.MaxNodesPerWindow > 0 && len(l.nodes)+1 > l.limits.MaxNodesPerWindow {
+		return ReasonNodeRate, false
+	}
+
+	l.nodes[nodeName] = now
+	return "", true
+}
+
+// Reset clears all the windows, used once the circuit breaker is reset
+func (l *Limiter) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.releases = nil
+	l.nodes = make(map[string]time.Time)
+}
+
+func (l *Limiter) pruneReleases(now time.Time) {
+	i := 0
+	for i < len(l.releases) && now.Sub(l.releases[i].time) > pvcWindow {
+		i++
+	}
+	l.releases = l.releases[i:]
+}
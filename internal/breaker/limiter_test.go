@@ -0,0 +1,73 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiterAdmitPVC(t *testing.T) {
+	now := time.Now()
+	limiter := NewLimiter(Limits{MaxPVCsPerMinute: 3, MaxNamespacePVCsPerMinute: 2})
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		_, ok := limiter.AdmitPVC("kafka")
+		assert.True(t, ok)
+		limiter.RecordPVC("kafka")
+	}
+
+	reason, ok := limiter.AdmitPVC("kafka")
+	assert.False(t, ok)
+	assert.Equal(t, ReasonNamespaceRate, reason)
+
+	_, ok = limiter.AdmitPVC("zookeeper")
+	assert.True(t, ok)
+	limiter.RecordPVC("zookeeper")
+
+	reason, ok = limiter.AdmitPVC("cassandra")
+	assert.False(t, ok)
+	assert.Equal(t, ReasonGlobalRate, reason)
+
+	// The window slides after a minute
+	now = now.Add(2 * time.Minute)
+	_, ok = limiter.AdmitPVC("kafka")
+	assert.True(t, ok)
+}
+
+func TestLimiterAdmitPVCWithoutRecord(t *testing.T) {
+	limiter := NewLimiter(Limits{MaxPVCsPerMinute: 1})
+
+	// Admitted releases that never completed do not consume the budget
+	for i := 0; i < 3; i++ {
+		_, ok := limiter.AdmitPVC("kafka")
+		assert.True(t, ok)
+	}
+
+	limiter.RecordPVC("kafka")
+	reason, ok := limiter.AdmitPVC("kafka")
+	assert.False(t, ok)
+	assert.Equal(t, ReasonGlobalRate, reason)
+}
+
+func TestLimiterAdmitNode(t *testing.T) {
+	now := time.Now()
+	limiter := NewLimiter(Limits{MaxNodesPerWindow: 2, NodeWindow: 10 * time.Minute})
+	limiter.now = func() time.Time { return now }
+
+	_, ok := limiter.AdmitNode("node-1")
+	assert.True(t, ok)
+	_, ok = limiter.AdmitNode("node-2")
+	assert.True(t, ok)
+	_, ok = limiter.AdmitNode("node-1")
+	assert.True(t, ok)
+
+	reason, ok := limiter.AdmitNode("node-3")
+	assert.False(t, ok)
+	assert.Equal(t, ReasonNodeRate, reason)
+
+	limiter.Reset()
+	_, ok = limiter.AdmitNode("node-3")
+	assert.True(t, ok)
+}
@@ -0,0 +1,122 @@
+package classifier
+
+import (
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	HostnameTopologyKey = "kubernetes.io/hostname"
+	metadataNameField   = "metadata.name"
+)
+
+// Classifier decides whether a PersistentVolume represents storage living on a single node
+type Classifier interface {
+	IsNodeLocal(pv *v1.PersistentVolume) bool
+}
+
+// LocalVolume classifies the in-tree 'local' volume plugin PVs as node-local
+type LocalVolume struct{}
+
+func (LocalVolume) IsNodeLocal(pv *v1.PersistentVolume) bool {
+	return pv.Spec.Local != nil
+}
+
+// CSIDrivers classifies the PVs provisioned by one of the given node-local CSI drivers (e.g. TopoLVM, OpenEBS LocalPV)
+type CSIDrivers struct {
+	Drivers map[string]struct{}
+}
+
+func NewCSIDrivers(drivers []string) *CSIDrivers {
+	c := &CSIDrivers{Drivers: make(map[string]struct{}, len(drivers))}
+	for _, driver := range drivers {
+		c.Drivers[driver] = struct{}{}
+	}
+
+	return c
+}
+
+func (c *CSIDrivers) IsNodeLocal(pv *v1.PersistentVolume) bool {
+	if pv.Spec.CSI == nil {
+		return false
+	}
+
+	_, exists := c.Drivers[pv.Spec.CSI.Driver]
+	return exists
+}
+
+// NodeAffinity classifies the PVs whose required node affinity pins them to a single node through one of the topology keys
+// (e.g. the hostPath PVs of the local-path provisioner)
+type NodeAffinity struct {
+	TopologyKeys []string
+}
+
+func (c *NodeAffinity) IsNodeLocal(pv *v1.PersistentVolume) bool {
+	for _, key := range c.TopologyKeys {
+		if _, pinned := PinnedNode(pv, key); pinned {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Chain classifies a PV as node-local when any of its classifiers does
+type Chain []Classifier
+
+func (c Chain) IsNodeLocal(pv *v1.PersistentVolume) bool {
+	for _, classifier := range c {
+		if classifier.IsNodeLocal(pv) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// New returns the built-in 'local' volume classifier, extended by the CSI drivers and topology keys when given
+func New(csiDrivers []string, topologyKeys []string) Classifier {
+	chain := Chain{LocalVolume{}}
+
+	if len(csiDrivers) > 0 {
+		chain = append(chain, NewCSIDrivers(csiDrivers))
+	}
+	if len(topologyKeys) > 0 {
+		chain = append(chain, &NodeAffinity{TopologyKeys: topologyKeys})
+	}
+
+	return chain
+}
+
+// PinnedNode returns the single topology value the PV required node affinity pins it to by the given key.
+// Every node selector term must require the key to be exactly that value, otherwise the PV may land on more than one node.
+func PinnedNode(pv *v1.PersistentVolume, key string) (string, bool) {
+	if pv.Spec.NodeAffinity == nil || pv.Spec.NodeAffinity.Required == nil || len(pv.Spec.NodeAffinity.Required.NodeSelectorTerms) == 0 {
+		return "", false
+	}
+
+	pinned := ""
+	for _, term := range pv.Spec.NodeAffinity.Required.NodeSelectorTerms {
+		value, found := termValue(term, key)
+		if !found || (pinned != "" && value != pinned) {
+			return "", false
+		}
+		pinned = value
+	}
+
+	return pinned, true
+}
+
+func termValue(term v1.NodeSelectorTerm, key string) (string, bool) {
+	requirements := term.MatchExpressions
+	if key == metadataNameField {
+		requirements = term.MatchFields
+	}
+
+	for _, requirement := range requirements {
+		if requirement.Key == key && requirement.Operator == v1.NodeSelectorOpIn && len(requirement.Values) == 1 {
+			return requirement.Values[0], true
+		}
+	}
+
+	return "", false
+}
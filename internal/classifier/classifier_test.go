@@ -0,0 +1,54 @@
+package classifier
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+)
+
+func pinnedPV(key string, values ...string) *v1.PersistentVolume {
+	return &v1.PersistentVolume{
+		Spec: v1.PersistentVolumeSpec{
+			NodeAffinity: &v1.VolumeNodeAffinity{
+				Required: &v1.NodeSelector{
+					NodeSelectorTerms: []v1.NodeSelectorTerm{
+						{
+							MatchExpressions: []v1.NodeSelectorRequirement{
+								{Key: key, Operator: v1.NodeSelectorOpIn, Values: values},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestClassifier(t *testing.T) {
+	classifier := New([]string{"topolvm.io"}, []string{HostnameTopologyKey})
+
+	local := &v1.PersistentVolume{Spec: v1.PersistentVolumeSpec{PersistentVolumeSource: v1.PersistentVolumeSource{Local: &v1.LocalVolumeSource{Path: "/mnt"}}}}
+	assert.True(t, classifier.IsNodeLocal(local))
+
+	topolvm := &v1.PersistentVolume{Spec: v1.PersistentVolumeSpec{PersistentVolumeSource: v1.PersistentVolumeSource{CSI: &v1.CSIPersistentVolumeSource{Driver: "topolvm.io"}}}}
+	assert.True(t, classifier.IsNodeLocal(topolvm))
+
+	ebs := &v1.PersistentVolume{Spec: v1.PersistentVolumeSpec{PersistentVolumeSource: v1.PersistentVolumeSource{CSI: &v1.CSIPersistentVolumeSource{Driver: "ebs.csi.aws.com"}}}}
+	assert.False(t, classifier.IsNodeLocal(ebs))
+
+	assert.True(t, classifier.IsNodeLocal(pinnedPV(HostnameTopologyKey, "node-1")))
+	assert.False(t, classifier.IsNodeLocal(pinnedPV(HostnameTopologyKey, "node-1", "node-2")))
+	assert.False(t, classifier.IsNodeLocal(pinnedPV("topology.kubernetes.io/zone", "us-east-1a")))
+
+	assert.False(t, New(nil, nil).IsNodeLocal(topolvm))
+}
+
+func TestPinnedNode(t *testing.T) {
+	nodeName, pinned := PinnedNode(pinnedPV(HostnameTopologyKey, "node-1"), HostnameTopologyKey)
+	assert.True(t, pinned)
+	assert.Equal(t, "node-1", nodeName)
+
+	_, pinned = PinnedNode(&v1.PersistentVolume{}, HostnameTopologyKey)
+	assert.False(t, pinned)
+}
@@ -0,0 +1,45 @@
+package controller
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/AppsFlyer/local-pvc-releaser/internal/triggers"
+)
+
+const lastAppliedConfigAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// CacheByObject restricts the Event cache to the events the trigger rules may match, optionally in a single namespace.
+// When transformVolumes is set, the PVCs and PVs are cached without their managed fields and last-applied configuration,
+// which the controller never reads.
+func CacheByObject(rules *triggers.RuleSet, eventNamespace string, transformVolumes bool) map[client.Object]cache.ByObject {
+	events := cache.ByObject{Field: rules.FieldSelector()}
+	if eventNamespace != "" {
+		events.Namespaces = map[string]cache.Config{eventNamespace: {}}
+	}
+
+	byObject := map[client.Object]cache.ByObject{&v1.Event{}: events}
+
+	if transformVolumes {
+		byObject[&v1.PersistentVolumeClaim{}] = cache.ByObject{Transform: stripUnusedFields}
+		byObject[&v1.PersistentVolume{}] = cache.ByObject{Transform: stripUnusedFields}
+	}
+
+	return byObject
+}
+
+func stripUnusedFields(in interface{}) (interface{}, error) {
+	obj, ok := in.(client.Object)
+	if !ok {
+		return in, nil
+	}
+
+	obj.SetManagedFields(nil)
+	if annotations := obj.GetAnnotations(); annotations != nil {
+		delete(annotations, lastAppliedConfigAnnotation)
+		obj.SetAnnotations(annotations)
+	}
+
+	return obj, nil
+}
@@ -0,0 +1,171 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/AppsFlyer/local-pvc-releaser/internal/breaker"
+	"github.com/AppsFlyer/local-pvc-releaser/internal/exporters"
+)
+
+const (
+	CircuitBreakerResetAnnotation = "appsflyer.com/circuit-breaker-reset"
+
+	circuitBreakerStateKey     = "state"
+	circuitBreakerReasonKey    = "reason"
+	circuitBreakerTrippedAtKey = "trippedAt"
+	circuitBreakerResetAtKey   = "resetAt"
+	circuitBreakerOpen         = "open"
+	circuitBreakerClosed       = "closed"
+
+	// circuitBreakerRecheckInterval is the interval in which releases held by an open circuit breaker are retried
+	circuitBreakerRecheckInterval = time.Minute
+)
+
+// CircuitBreaker stops all the PVC deletions once one of the blast radius limits is exceeded.
+// Its state is kept in a ConfigMap so it survives restarts and leader changes. Deletions resume only after an operator
+// resets it, either by annotating the ConfigMap with appsflyer.com/circuit-breaker-reset=true or by setting its state to closed.
+type CircuitBreaker struct {
+	Client    client.Client
+	Reader    client.Reader
+	Namespace string
+	Name      string
+	Limiter   *breaker.Limiter
+	Recorder  record.EventRecorder
+	Collector *exporters.Collector
+	Logger    *logr.Logger
+
+	wasOpen atomic.Bool
+}
+
+// IsOpen reads the circuit breaker state, applying a reset requested by the operator
+func (b *CircuitBreaker) IsOpen(ctx context.Context) (bool, error) {
+	configMap := &v1.ConfigMap{}
+	if err := b.Reader.Get(ctx, client.ObjectKey{Namespace: b.Namespace, Name: b.Name}, configMap); err != nil {
+		if apierrors.IsNotFound(err) {
+			b.Collector.CircuitBreakerOpen.Set(0)
+			return false, nil
+		}
+		return false, err
+	}
+
+	if configMap.Annotations[CircuitBreakerResetAnnotation] == "true" {
+		return false, b.reset(ctx, configMap)
+	}
+
+	if configMap.Data[circuitBreakerStateKey] == circuitBreakerOpen {
+		b.wasOpen.Store(true)
+		b.Collector.CircuitBreakerOpen.Set(1)
+		return true, nil
+	}
+
+	// The operator closed the circuit breaker by editing its state
+	if b.wasOpen.Swap(false) {
+		b.Limiter.Reset()
+		b.Logger.Info("circuit breaker was closed by the operator, pvc deletions are resumed", "ConfigMap", client.ObjectKeyFromObject(configMap))
+	}
+
+	b.Collector.CircuitBreakerOpen.Set(0)
+	return false, nil
+}
+
+// AdmitNode reports whether the release of the given node may start
+func (b *CircuitBreaker) AdmitNode(ctx context.Context, nodeName string) (bool, error) {
+	if open, err := b.IsOpen(ctx); err != nil || open {
+		return false, err
+	}
+
+	if reason, admitted := b.Limiter.AdmitNode(nodeName); !admitted {
+		return false, b.trip(ctx, reason, fmt.Sprintf("processing node %s", nodeName))
+	}
+
+	return true, nil
+}
+
+// AdmitPVC reports whether the given PVC may be deleted, tripping the circuit breaker once its release would exceed a limit.
+// The open state is not read again, callers check IsOpen once before releasing a batch of PVCs.
+func (b *CircuitBreaker) AdmitPVC(ctx context.Context, pvc *v1.PersistentVolumeClaim) (bool, error) {
+	if reason, admitted := b.Limiter.AdmitPVC(pvc.Namespace); !admitted {
+		return false, b.trip(ctx, reason, fmt.Sprintf("releasing pvc %s/%s", pvc.Namespace, pvc.Name))
+	}
+
+	return true, nil
+}
+
+// RecordRelease counts the completed release of the given PVC against the limits
+func (b *CircuitBreaker) RecordRelease(pvc *v1.PersistentVolumeClaim) {
+	b.Limiter.RecordPVC(pvc.Namespace)
+}
+
+func (b *CircuitBreaker) trip(ctx context.Context, reason string, subject string) error {
+	configMap := &v1.ConfigMap{}
+	err := b.Reader.Get(ctx, client.ObjectKey{Namespace: b.Namespace, Name: b.Name}, configMap)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	exists := err == nil
+	if !exists {
+		configMap = &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: b.Namespace,
+				Name:      b.Name,
+			},
+		}
+	}
+	if configMap.Data == nil {
+		configMap.Data = make(map[string]string)
+	}
+	configMap.Data[circuitBreakerStateKey] = circuitBreakerOpen
+	configMap.Data[circuitBreakerReasonKey] = reason
+	configMap.Data[circuitBreakerTrippedAtKey] = time.Now().UTC().Format(time.RFC3339)
+
+	if exists {
+		err = b.Client.Update(ctx, configMap)
+	} else {
+		err = b.Client.Create(ctx, configMap)
+	}
+	if err != nil {
+		return err
+	}
+
+	b.Logger.Info(fmt.Sprintf("circuit breaker tripped on %s, all pvc deletions are stopped until it is reset", subject), "Reason", reason, "ConfigMap", client.ObjectKeyFromObject(configMap))
+	b.Recorder.Eventf(configMap, "Warning", "CircuitBreaker-Tripped", "The %s limit was exceeded on %s, all PersistentVolumeClaims releases are stopped until the circuit breaker is reset", reason, subject)
+	b.wasOpen.Store(true)
+	b.Collector.CircuitBreakerTrips.With(prometheus.Labels{"reason": reason}).Inc()
+	b.Collector.CircuitBreakerOpen.Set(1)
+
+	return nil
+}
+
+func (b *CircuitBreaker) reset(ctx context.Context, configMap *v1.ConfigMap) error {
+	delete(configMap.Annotations, CircuitBreakerResetAnnotation)
+	if configMap.Data == nil {
+		configMap.Data = make(map[string]string)
+	}
+	configMap.Data[circuitBreakerStateKey] = circuitBreakerClosed
+	configMap.Data[circuitBreakerResetAtKey] = time.Now().UTC().Format(time.RFC3339)
+
+	if err := b.Client.Update(ctx, configMap); err != nil {
+		return err
+	}
+
+	b.wasOpen.Store(false)
+	b.Limiter.Reset()
+
+	b.Logger.Info("circuit breaker was reset by the operator, pvc deletions are resumed", "ConfigMap", client.ObjectKeyFromObject(configMap))
+	b.Recorder.Eventf(configMap, "Normal", "CircuitBreaker-Reset", "The circuit breaker was reset, PersistentVolumeClaims releases are resumed")
+	b.Collector.CircuitBreakerOpen.Set(0)
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	"github.com/AppsFlyer/local-pvc-releaser/internal/breaker"
+)
+
+func withTestBreaker(r *PVCReconciler, limits breaker.Limits) *CircuitBreaker {
+	r.Breaker = &CircuitBreaker{
+		Client:    r.Client,
+		Reader:    r.Client,
+		Namespace: "local-pvc-releaser",
+		Name:      "circuit-breaker",
+		Limiter:   breaker.NewLimiter(limits),
+		Recorder:  r.Recorder,
+		Collector: r.Collector,
+		Logger:    r.Logger,
+	}
+
+	return r.Breaker
+}
+
+func breakerState(t *testing.T, b *CircuitBreaker) string {
+	t.Helper()
+
+	configMap := &v1.ConfigMap{}
+	err := b.Client.Get(context.Background(), client.ObjectKey{Namespace: b.Namespace, Name: b.Name}, configMap)
+	if apierrors.IsNotFound(err) {
+		return ""
+	}
+	require.NoError(t, err)
+
+	return configMap.Data[circuitBreakerStateKey]
+}
+
+func TestCircuitBreakerCountsOnlyCompletedReleases(t *testing.T) {
+	funcs := interceptor.Funcs{
+		Delete: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+			if obj.GetName() == "data-0" {
+				return errors.New("delete failed")
+			}
+			return c.Delete(ctx, obj, opts...)
+		},
+	}
+	r := newTestReconciler(t, funcs,
+		testPVC("data-0", "pv-0"), testLocalPV("pv-0"),
+		testPVC("data-1", "pv-1"), testLocalPV("pv-1"),
+		testPVC("data-2", "pv-2"), testLocalPV("pv-2"),
+	)
+	b := withTestBreaker(r, breaker.Limits{MaxPVCsPerMinute: 1})
+
+	_, err := r.Reconcile(context.Background(), deleteNode(r))
+	require.Error(t, err)
+
+	// The failed delete of data-0 did not consume the budget of data-1, while data-2 exceeded it
+	assert.True(t, pvcExists(t, r, "data-0"))
+	assert.False(t, pvcExists(t, r, "data-1"))
+	assert.True(t, pvcExists(t, r, "data-2"))
+	assert.Equal(t, circuitBreakerOpen, breakerState(t, b))
+}
+
+func TestCircuitBreakerIgnoresGlobalDryRun(t *testing.T) {
+	r := newTestReconciler(t, interceptor.Funcs{},
+		testPVC("data-0", "pv-0"), testLocalPV("pv-0"),
+		testPVC("data-1", "pv-1"), testLocalPV("pv-1"),
+	)
+	r.DryRun = true
+	r.Client = client.NewDryRunClient(r.Client)
+	b := withTestBreaker(r, breaker.Limits{MaxPVCsPerMinute: 1, MaxNodesPerWindow: 1})
+
+	_, err := r.Reconcile(context.Background(), deleteNode(r))
+	require.NoError(t, err)
+
+	assert.True(t, r.tracker.IsReleased(nodeTermination{NodeName: testNode}))
+	assert.Empty(t, breakerState(t, b))
+	_, admitted := b.Limiter.AdmitPVC("default")
+	assert.True(t, admitted)
+}
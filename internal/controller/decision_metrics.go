@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "k8s.io/api/core/v1"
+)
+
+// pvcLabels returns the namespace and storage class labels shared by the per-decision metrics
+func pvcLabels(pvc *v1.PersistentVolumeClaim) prometheus.Labels {
+	storageClass := ""
+	if pvc.Spec.StorageClassName != nil {
+		storageClass = *pvc.Spec.StorageClassName
+	}
+
+	return prometheus.Labels{"namespace": pvc.Namespace, "storage_class": storageClass}
+}
+
+func (r *PVCReconciler) recordEvaluated(pvc *v1.PersistentVolumeClaim) {
+	r.Collector.EvaluatedPVC.With(pvcLabels(pvc)).Inc()
+}
+
+func (r *PVCReconciler) recordSkipped(pvc *v1.PersistentVolumeClaim, reason string) {
+	labels := pvcLabels(pvc)
+	labels["reason"] = reason
+	r.Collector.SkippedPVC.With(labels).Inc()
+}
+
+func (r *PVCReconciler) recordFailed(pvc *v1.PersistentVolumeClaim) {
+	r.Collector.FailedPVC.With(pvcLabels(pvc)).Inc()
+}
+
+// recordReleased counts the release, and observes the latency from the node termination when it is known
+func (r *PVCReconciler) recordReleased(pvc *v1.PersistentVolumeClaim, dryrun bool, terminatedAt time.Time) {
+	labels := pvcLabels(pvc)
+	if !terminatedAt.IsZero() {
+		r.Collector.ReleaseLatency.With(labels).Observe(time.Since(terminatedAt).Seconds())
+	}
+
+	labels["dryrun"] = strconv.FormatBool(dryrun)
+	r.Collector.ReleasedPVC.With(labels).Inc()
+}
+
+func (r *PVCReconciler) recordRescheduled(pvc *v1.PersistentVolumeClaim, dryrun bool) {
+	labels := pvcLabels(pvc)
+	labels["dryrun"] = strconv.FormatBool(dryrun)
+	r.Collector.RescheduledPVC.With(labels).Inc()
+}
+
+func (r *PVCReconciler) recordEphemeralReleased(pvc *v1.PersistentVolumeClaim, dryrun bool) {
+	labels := pvcLabels(pvc)
+	labels["dryrun"] = strconv.FormatBool(dryrun)
+	r.Collector.EphemeralPVCReleased.With(labels).Inc()
+}
@@ -0,0 +1,135 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	"github.com/AppsFlyer/local-pvc-releaser/internal/exporters"
+)
+
+func decisionLabels(extra ...string) prometheus.Labels {
+	labels := prometheus.Labels{"namespace": "default", "storage_class": "local-storage"}
+	for i := 0; i+1 < len(extra); i += 2 {
+		labels[extra[i]] = extra[i+1]
+	}
+
+	return labels
+}
+
+func TestDecisionMetrics(t *testing.T) {
+	failPVCDelete := interceptor.Funcs{
+		Delete: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+			if _, ok := obj.(*v1.PersistentVolumeClaim); ok {
+				return errors.New("delete failed")
+			}
+			return c.Delete(ctx, obj, opts...)
+		},
+	}
+	ephemeralPVC, ephemeralPod := testEphemeralPVC("worker-0")
+	networkPV := &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pv-0"},
+		Spec: v1.PersistentVolumeSpec{
+			PersistentVolumeSource: v1.PersistentVolumeSource{NFS: &v1.NFSVolumeSource{Server: "nfs", Path: "/exports"}},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		funcs   interceptor.Funcs
+		objs    []client.Object
+		setup   func(r *PVCReconciler)
+		wantErr bool
+		verify  func(t *testing.T, r *PVCReconciler)
+	}{
+		{
+			name: "release",
+			objs: []client.Object{testPVC("data-0", "pv-0"), testLocalPV("pv-0")},
+			verify: func(t *testing.T, r *PVCReconciler) {
+				assert.Equal(t, 1.0, testutil.ToFloat64(r.Collector.EvaluatedPVC.With(decisionLabels())))
+				assert.Equal(t, 1.0, testutil.ToFloat64(r.Collector.ReleasedPVC.With(decisionLabels("dryrun", "false"))))
+				assert.Equal(t, 1.0, testutil.ToFloat64(r.Collector.DeletedPVC.With(prometheus.Labels{"dryrun": "false"})))
+				assert.Equal(t, 1, testutil.CollectAndCount(r.Collector.ReleaseLatency))
+				assert.Equal(t, 0, testutil.CollectAndCount(r.Collector.SkippedPVC))
+			},
+		},
+		{
+			name: "skip",
+			objs: []client.Object{testPVC("data-0", "pv-0"), networkPV},
+			verify: func(t *testing.T, r *PVCReconciler) {
+				assert.Equal(t, 1.0, testutil.ToFloat64(r.Collector.SkippedPVC.With(decisionLabels("reason", exporters.SkipReasonNotLocal))))
+				assert.Equal(t, 0, testutil.CollectAndCount(r.Collector.ReleasedPVC))
+			},
+		},
+		{
+			name:    "failure",
+			funcs:   failPVCDelete,
+			objs:    []client.Object{testPVC("data-0", "pv-0"), testLocalPV("pv-0")},
+			wantErr: true,
+			verify: func(t *testing.T, r *PVCReconciler) {
+				assert.Equal(t, 1.0, testutil.ToFloat64(r.Collector.FailedPVC.With(decisionLabels())))
+				assert.Equal(t, 0, testutil.CollectAndCount(r.Collector.ReleasedPVC))
+			},
+		},
+		{
+			name: "reschedule",
+			objs: []client.Object{waitForFirstConsumerClass(), testPVC("data-0", "")},
+			setup: func(r *PVCReconciler) {
+				r.UnboundPVCAction = UnboundPVCActionReschedule
+			},
+			verify: func(t *testing.T, r *PVCReconciler) {
+				assert.Equal(t, 1.0, testutil.ToFloat64(r.Collector.RescheduledPVC.With(decisionLabels("dryrun", "false"))))
+				assert.Equal(t, 0, testutil.CollectAndCount(r.Collector.ReleasedPVC))
+			},
+		},
+		{
+			name: "ephemeral",
+			objs: []client.Object{ephemeralPVC, ephemeralPod, testLocalPV("pv-0")},
+			verify: func(t *testing.T, r *PVCReconciler) {
+				assert.Equal(t, 1.0, testutil.ToFloat64(r.Collector.EphemeralPVCReleased.With(decisionLabels("dryrun", "false"))))
+				assert.Equal(t, 1.0, testutil.ToFloat64(r.Collector.ReleasedPVC.With(decisionLabels("dryrun", "false"))))
+				assert.Equal(t, 0, testutil.CollectAndCount(r.Collector.DeletedPVC))
+			},
+		},
+		{
+			name: "reprovision",
+			objs: []client.Object{testPVC("data-0", "pv-0"), testLocalPV("pv-0")},
+			setup: func(r *PVCReconciler) {
+				r.Reprovisioner = newTestReprovisioner(r, r.Client)
+			},
+			verify: func(t *testing.T, r *PVCReconciler) {
+				r.Reprovisioner.check(context.Background())
+
+				assert.Equal(t, 1.0, testutil.ToFloat64(r.Collector.ReleasedPVC.With(decisionLabels("dryrun", "false"))))
+				assert.Equal(t, 1.0, testutil.ToFloat64(r.Collector.ReprovisionedPVC.With(decisionLabels("dryrun", "false"))))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestReconciler(t, tt.funcs, tt.objs...)
+			if tt.setup != nil {
+				tt.setup(r)
+			}
+
+			_, err := r.Reconcile(context.Background(), deleteNode(r))
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			tt.verify(t, r)
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ephemeralVolumeOwner returns the owning pod of a generic ephemeral volume PVC, or nil for any other PVC
+func ephemeralVolumeOwner(pvc *v1.PersistentVolumeClaim) *metav1.OwnerReference {
+	owner := metav1.GetControllerOf(pvc)
+	if owner == nil || owner.Kind != "Pod" || owner.APIVersion != v1.SchemeGroupVersion.String() {
+		return nil
+	}
+
+	return owner
+}
+
+// releaseEphemeralVolume deletes the owning pod of a generic ephemeral volume PVC instead of the PVC itself.
+// The ephemeral volume controller would otherwise fight the PVC deletion, while the pod deletion garbage collects the PVC,
+// and the pod's controller recreates both. It reports whether the pod was deleted, a pod already gone or
+// re-created under the same name is left alone, as the PVC is garbage collected anyway.
+func (r *PVCReconciler) releaseEphemeralVolume(ctx context.Context, pvc *v1.PersistentVolumeClaim, owner *metav1.OwnerReference, dryrun bool) (bool, error) {
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: pvc.Namespace, Name: owner.Name}}
+
+	// The pod is on the removed node, so there is no kubelet to complete a graceful deletion
+	deleteOpts := []client.DeleteOption{client.GracePeriodSeconds(0), client.Preconditions{UID: &owner.UID}}
+	if dryrun && !r.DryRun {
+		deleteOpts = append(deleteOpts, client.DryRunAll)
+	}
+
+	if err := r.Delete(ctx, pod, deleteOpts...); err != nil {
+		if apierrors.IsNotFound(err) || apierrors.IsConflict(err) {
+			r.Logger.Info(fmt.Sprintf("ephemeral pvc - %s owning pod - %s no longer exists and will be skipped", pvc.Name, owner.Name), "Namespace", pvc.Namespace)
+			return false, nil
+		}
+		return false, errors.Wrap(err, fmt.Sprintf("failed to delete the owning pod - %s of object - %s,", owner.Name, pvc.GetName()))
+	}
+
+	dryrun = dryrun || r.DryRun
+	if dryrun {
+		r.Recorder.Eventf(pvc, "Normal", "Ephemeral-PVC-Release-DryRun", "The owning Pod %s of the ephemeral PersistentVolumeClaim %s would have been deleted", owner.Name, pvc.Name)
+	} else {
+		r.Recorder.Eventf(pvc, "Normal", "Ephemeral-PVC-Released", "The owning Pod %s of the ephemeral PersistentVolumeClaim %s was deleted, the PersistentVolumeClaim is garbage collected", owner.Name, pvc.Name)
+	}
+	r.recordEphemeralReleased(pvc, dryrun)
+
+	r.Logger.Info(fmt.Sprintf("ephemeral pvc object - %s was released by deleting its owning pod - %s", pvc.GetName(), owner.Name), "dryrun", dryrun)
+
+	return true, nil
+}
@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func testEphemeralPVC(podName string) (*v1.PersistentVolumeClaim, *v1.Pod) {
+	pod := testPod(podName, testNode, podName+"-data")
+	pod.UID = "pod-uid"
+
+	controller := true
+	pvc := testPVC(podName+"-data", "pv-0")
+	pvc.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: "v1",
+		Kind:       "Pod",
+		Name:       pod.Name,
+		UID:        pod.UID,
+		Controller: &controller,
+	}}
+
+	return pvc, pod
+}
+
+func TestEphemeralVolumeRelease(t *testing.T) {
+	pvc, pod := testEphemeralPVC("worker-0")
+	r := newTestReconciler(t, interceptor.Funcs{}, pvc, pod, testLocalPV("pv-0"),
+		testVolumeAttachment("csi-removed", testNode, "pv-0"),
+	)
+	r.CleanVolumeAttachments = true
+	r.Recovery = &RecoveryTracker{Client: r.Client, Recorder: r.Recorder, Collector: r.Collector, Logger: r.Logger}
+	r.PVJanitor = &PVJanitor{Client: r.Client, Recorder: r.Recorder, Collector: r.Collector, Logger: r.Logger}
+
+	_, err := r.Reconcile(context.Background(), deleteNode(r))
+	require.NoError(t, err)
+
+	// The owning pod is deleted instead of the PVC, which is garbage collected with it
+	assert.False(t, podExists(t, r, "worker-0"))
+	assert.True(t, pvcExists(t, r, "worker-0-data"))
+
+	// The release is followed up like any other release
+	assert.Equal(t, 1, r.Recovery.released.len())
+	assert.Equal(t, 1, r.PVJanitor.orphaned.len())
+	assert.False(t, volumeAttachmentExists(t, r, "csi-removed"))
+	assert.True(t, r.tracker.IsReleased(nodeTermination{NodeName: testNode}))
+}
@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"context"
+
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/AppsFlyer/local-pvc-releaser/internal/classifier"
+)
+
+const (
+	// PVCSelectedNodeIndex indexes the PVCs by the node of their selected-node annotation
+	PVCSelectedNodeIndex = "metadata.annotations.selected-node"
+	// PVNodeAffinityIndex indexes the PVs by the single node their required node affinity pins them to
+	PVNodeAffinityIndex = "spec.nodeAffinity.node"
+	// VolumeAttachmentPVIndex indexes the VolumeAttachments by the name of their PV
+	VolumeAttachmentPVIndex = "spec.source.persistentVolumeName"
+)
+
+func pvcSelectedNodeIndexer(obj client.Object) []string {
+	if nodeName := obj.GetAnnotations()[PVCnodeAnnotationKey]; nodeName != "" {
+		return []string{nodeName}
+	}
+
+	return nil
+}
+
+func pvNodeAffinityIndexer(topologyKey string) client.IndexerFunc {
+	return func(obj client.Object) []string {
+		pv, ok := obj.(*v1.PersistentVolume)
+		if !ok {
+			return nil
+		}
+
+		if nodeName, pinned := classifier.PinnedNode(pv, topologyKey); pinned {
+			return []string{nodeName}
+		}
+
+		return nil
+	}
+}
+
+func volumeAttachmentPVIndexer(obj client.Object) []string {
+	attachment, ok := obj.(*storagev1.VolumeAttachment)
+	if !ok || attachment.Spec.Source.PersistentVolumeName == nil {
+		return nil
+	}
+
+	return []string{*attachment.Spec.Source.PersistentVolumeName}
+}
+
+// setupIndexers registers the field indexers on the manager cache, so the PVCs, PVs and VolumeAttachments are queried
+// with client.MatchingFields instead of listing every object in the cluster
+func (r *PVCReconciler) setupIndexers(ctx context.Context, mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &v1.PersistentVolumeClaim{}, PVCSelectedNodeIndex, pvcSelectedNodeIndexer); err != nil {
+		return err
+	}
+
+	if r.PVNodeAffinityDiscovery {
+		if err := mgr.GetFieldIndexer().IndexField(ctx, &v1.PersistentVolume{}, PVNodeAffinityIndex, pvNodeAffinityIndexer(r.NodeTopologyKey)); err != nil {
+			return err
+		}
+	}
+
+	if r.CleanVolumeAttachments {
+		if err := mgr.GetFieldIndexer().IndexField(ctx, &storagev1.VolumeAttachment{}, VolumeAttachmentPVIndex, volumeAttachmentPVIndexer); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,200 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	"github.com/AppsFlyer/local-pvc-releaser/internal/classifier"
+)
+
+func pinnedPV(name string, terms ...v1.NodeSelectorTerm) *v1.PersistentVolume {
+	pv := testLocalPV(name)
+	if len(terms) > 0 {
+		pv.Spec.NodeAffinity = &v1.VolumeNodeAffinity{Required: &v1.NodeSelector{NodeSelectorTerms: terms}}
+	}
+
+	return pv
+}
+
+func hostnameTerm(values ...string) v1.NodeSelectorTerm {
+	return v1.NodeSelectorTerm{MatchExpressions: []v1.NodeSelectorRequirement{{
+		Key:      classifier.HostnameTopologyKey,
+		Operator: v1.NodeSelectorOpIn,
+		Values:   values,
+	}}}
+}
+
+func TestPVCSelectedNodeIndexer(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		expected    []string
+	}{
+		{name: "selected node", annotations: map[string]string{PVCnodeAnnotationKey: testNode}, expected: []string{testNode}},
+		{name: "no annotations", annotations: nil, expected: nil},
+		{name: "empty selected node", annotations: map[string]string{PVCnodeAnnotationKey: ""}, expected: nil},
+		{name: "other annotations", annotations: map[string]string{"appsflyer.com/local-pvc-releaser": "enabled"}, expected: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pvc := &v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "data-0", Annotations: test.annotations}}
+			assert.Equal(t, test.expected, pvcSelectedNodeIndexer(pvc))
+		})
+	}
+}
+
+func TestPVNodeAffinityIndexer(t *testing.T) {
+	tests := []struct {
+		name     string
+		obj      client.Object
+		expected []string
+	}{
+		{name: "pinned to a single node", obj: pinnedPV("pv-0", hostnameTerm(testNode)), expected: []string{testNode}},
+		{name: "no node affinity", obj: pinnedPV("pv-0"), expected: nil},
+		{name: "several nodes", obj: pinnedPV("pv-0", hostnameTerm(testNode, "node-2")), expected: nil},
+		{name: "several terms", obj: pinnedPV("pv-0", hostnameTerm(testNode), hostnameTerm("node-2")), expected: nil},
+		{name: "not a pv", obj: &v1.PersistentVolumeClaim{}, expected: nil},
+	}
+
+	indexer := pvNodeAffinityIndexer(classifier.HostnameTopologyKey)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, indexer(test.obj))
+		})
+	}
+}
+
+func TestVolumeAttachmentPVIndexer(t *testing.T) {
+	pvName := "pv-0"
+
+	tests := []struct {
+		name     string
+		obj      client.Object
+		expected []string
+	}{
+		{name: "persistent volume source", obj: &storagev1.VolumeAttachment{Spec: storagev1.VolumeAttachmentSpec{Source: storagev1.VolumeAttachmentSource{PersistentVolumeName: &pvName}}}, expected: []string{pvName}},
+		{name: "inline volume source", obj: &storagev1.VolumeAttachment{Spec: storagev1.VolumeAttachmentSpec{Source: storagev1.VolumeAttachmentSource{InlineVolumeSpec: &v1.PersistentVolumeSpec{}}}}, expected: nil},
+		{name: "not a volume attachment", obj: &v1.PersistentVolume{}, expected: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, volumeAttachmentPVIndexer(test.obj))
+		})
+	}
+}
+
+func TestListByIndexes(t *testing.T) {
+	otherNodePVC := testPVC("data-1", "pv-1")
+	otherNodePVC.Annotations[PVCnodeAnnotationKey] = "node-2"
+	pvName := "pv-0"
+	r := newTestReconciler(t, interceptor.Funcs{},
+		testPVC("data-0", "pv-0"), otherNodePVC,
+		pinnedPV("pv-0", hostnameTerm(testNode)), pinnedPV("pv-1", hostnameTerm("node-2")),
+		&storagev1.VolumeAttachment{
+			ObjectMeta: metav1.ObjectMeta{Name: "csi-0"},
+			Spec:       storagev1.VolumeAttachmentSpec{NodeName: testNode, Source: storagev1.VolumeAttachmentSource{PersistentVolumeName: &pvName}},
+		},
+	)
+	ctx := context.Background()
+
+	pvcList := &v1.PersistentVolumeClaimList{}
+	require.NoError(t, r.List(ctx, pvcList, client.MatchingFields{PVCSelectedNodeIndex: testNode}))
+	require.Len(t, pvcList.Items, 1)
+	assert.Equal(t, "data-0", pvcList.Items[0].Name)
+
+	pvList := &v1.PersistentVolumeList{}
+	require.NoError(t, r.List(ctx, pvList, client.MatchingFields{PVNodeAffinityIndex: testNode}))
+	require.Len(t, pvList.Items, 1)
+	assert.Equal(t, "pv-0", pvList.Items[0].Name)
+
+	attachmentList := &storagev1.VolumeAttachmentList{}
+	require.NoError(t, r.List(ctx, attachmentList, client.MatchingFields{VolumeAttachmentPVIndex: pvName}))
+	require.Len(t, attachmentList.Items, 1)
+	assert.Equal(t, "csi-0", attachmentList.Items[0].Name)
+}
+
+const (
+	benchmarkPVCs  = 50000
+	benchmarkNodes = 500
+)
+
+// benchmarkStore returns an informer store holding the benchmark PVCs, indexed the same way the manager cache is
+func benchmarkStore(b *testing.B) toolscache.Indexer {
+	b.Helper()
+
+	store := toolscache.NewIndexer(toolscache.MetaNamespaceKeyFunc, toolscache.Indexers{
+		PVCSelectedNodeIndex: func(obj interface{}) ([]string, error) {
+			return pvcSelectedNodeIndexer(obj.(client.Object)), nil
+		},
+	})
+
+	for i := 0; i < benchmarkPVCs; i++ {
+		pvc := &v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        fmt.Sprintf("pvc-%d", i),
+				Namespace:   "default",
+				Annotations: map[string]string{PVCnodeAnnotationKey: fmt.Sprintf("node-%d", i%benchmarkNodes)},
+			},
+		}
+		if err := store.Add(pvc); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	return store
+}
+
+// toPVCList deep copies the objects read from the store, as the cache reader does
+func toPVCList(objects []interface{}) *v1.PersistentVolumeClaimList {
+	pvcList := &v1.PersistentVolumeClaimList{Items: make([]v1.PersistentVolumeClaim, 0, len(objects))}
+	for _, obj := range objects {
+		pvcList.Items = append(pvcList.Items, *obj.(*v1.PersistentVolumeClaim).DeepCopy())
+	}
+
+	return pvcList
+}
+
+// BenchmarkListNodePVCs compares listing every PVC in the cluster and filtering by the selected-node annotation,
+// to querying the selected-node field index, at 50k PVCs spread over 500 nodes
+func BenchmarkListNodePVCs(b *testing.B) {
+	store := benchmarkStore(b)
+	logger := logr.Discard()
+	r := &PVCReconciler{Logger: &logger}
+
+	b.Run("list-all-and-filter", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			pvcs := r.FilterPVCListByNodeName(toPVCList(store.List()), "node-1")
+			if len(pvcs) != benchmarkPVCs/benchmarkNodes {
+				b.Fatalf("expected %d pvcs, found %d", benchmarkPVCs/benchmarkNodes, len(pvcs))
+			}
+		}
+	})
+
+	b.Run("field-index", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			objects, err := store.ByIndex(PVCSelectedNodeIndex, "node-1")
+			if err != nil {
+				b.Fatal(err)
+			}
+			pvcs := r.FilterPVCListByNodeName(toPVCList(objects), "node-1")
+			if len(pvcs) != benchmarkPVCs/benchmarkNodes {
+				b.Fatalf("expected %d pvcs, found %d", benchmarkPVCs/benchmarkNodes, len(pvcs))
+			}
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// OutOfServiceTaintKey marks a node an operator confirmed as shut down, its Node object may still exist
+const OutOfServiceTaintKey = "node.kubernetes.io/out-of-service"
+
+// verifyNodeGone checks the Node API before any deletion, rather than trusting the termination signal.
+// The release is refused when the terminated Node still exists with the same UID or is Ready,
+// unless it carries the out-of-service taint that confirms it is dead.
+func (r *PVCReconciler) verifyNodeGone(ctx context.Context, termination nodeTermination) (bool, error) {
+	node := &v1.Node{}
+	if err := r.Get(ctx, client.ObjectKey{Name: termination.NodeName}, node); err != nil {
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+
+	for _, taint := range node.Spec.Taints {
+		if taint.Key == OutOfServiceTaintKey {
+			r.Logger.Info(fmt.Sprintf("node - %s still exists but carries the %s taint, it is confirmed dead", node.Name, OutOfServiceTaintKey), "NodeID", node.UID)
+			return true, nil
+		}
+	}
+
+	var reason string
+	switch {
+	case termination.NodeUID != "" && node.UID == termination.NodeUID:
+		reason = "still exists"
+	case nodeReady(node):
+		reason = "exists and is Ready"
+	default:
+		return true, nil
+	}
+
+	r.Logger.Info(fmt.Sprintf("node - %s %s, pvc release is refused", node.Name, reason), "NodeID", node.UID, "TerminatedNodeID", termination.NodeUID, "Source", termination.Source)
+	r.Recorder.Eventf(node, "Warning", "PVC-Release-Refused", "The node %s %s, PersistentVolumeClaims release was refused", node.Name, reason)
+	r.Collector.RefusedRelease.Inc()
+
+	return false, nil
+}
+
+func nodeReady(node *v1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == v1.NodeReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+
+	return false
+}
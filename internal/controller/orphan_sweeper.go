@@ -0,0 +1,213 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// OrphanSweeper releases local PVCs that are pinned to nodes which no longer exist.
+// It covers node removals that happened while the controller was down and were never reconciled.
+// The sweep runs once on startup and then periodically on the configured interval.
+// As the removal time of a node is unknown, the first sweep that found it missing stands for it, so the release grace
+// period and the release policies delays are counted from it, and a node that comes back starts over.
+// Each swept node is admitted by the circuit breaker like a terminated node, and a held node is retried by the next sweep.
+type OrphanSweeper struct {
+	Reconciler *PVCReconciler
+	Interval   time.Duration
+	DryRun     bool
+
+	missingSince map[string]time.Time
+}
+
+// Start implements manager.Runnable
+func (s *OrphanSweeper) Start(ctx context.Context) error {
+	s.sweep(ctx)
+
+	if s.Interval <= 0 {
+		return nil
+	}
+
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			s.sweep(ctx)
+		}
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable, only the leader is allowed to release PVCs
+func (s *OrphanSweeper) NeedLeaderElection() bool {
+	return true
+}
+
+func (s *OrphanSweeper) sweep(ctx context.Context) {
+	r := s.Reconciler
+	r.Logger.Info("starting orphan pvc sweep")
+	r.Collector.OrphanSweeps.Inc()
+
+	orphans, err := s.FindOrphanPVCs(ctx)
+	if err != nil {
+		r.Logger.Error(err, "failed to find orphan pvc objects")
+		return
+	}
+
+	nodeOrphans := make(map[string][]*v1.PersistentVolumeClaim)
+	for _, pvc := range orphans {
+		nodeName := pvc.Annotations[PVCnodeAnnotationKey]
+		nodeOrphans[nodeName] = append(nodeOrphans[nodeName], pvc)
+	}
+	s.trackMissingNodes(nodeOrphans, s.retryPendingCleanups(ctx, nodeOrphans))
+
+	handled := 0
+	for nodeName, pvcs := range nodeOrphans {
+		missingSince := s.missingSince[nodeName]
+		if remaining := time.Until(missingSince.Add(r.ReleaseDelay)); remaining > 0 {
+			r.Logger.Info(fmt.Sprintf("release of nonexistent node - %s orphan pvc objects is delayed by the grace period", nodeName), "Remaining", remaining)
+			continue
+		}
+
+		handled += s.release(ctx, nodeName, pvcs, missingSince)
+	}
+
+	if handled == 0 {
+		r.Logger.Info("orphan pvc sweep completed, no orphan pvc objects were released")
+		return
+	}
+
+	r.Logger.Info(fmt.Sprintf("orphan pvc sweep completed, %d orphan pvc objects were handled", handled))
+}
+
+// trackMissingNodes records the first sweep that found each node missing, and forgets the nodes that came back
+// or have no orphan PVCs left, unless their VolumeAttachments cleanups are still pending
+func (s *OrphanSweeper) trackMissingNodes(nodeOrphans map[string][]*v1.PersistentVolumeClaim, pending map[string]struct{}) {
+	if s.missingSince == nil {
+		s.missingSince = make(map[string]time.Time)
+	}
+
+	for nodeName := range s.missingSince {
+		_, missing := nodeOrphans[nodeName]
+		_, cleaning := pending[nodeName]
+		if !missing && !cleaning {
+			delete(s.missingSince, nodeName)
+		}
+	}
+
+	now := time.Now()
+	for nodeName := range nodeOrphans {
+		if _, tracked := s.missingSince[nodeName]; !tracked {
+			s.missingSince[nodeName] = now
+		}
+	}
+}
+
+// retryPendingCleanups retries the failed VolumeAttachments cleanups of the nodes whose orphan PVCs were all released,
+// and returns the nodes whose cleanups are still pending
+func (s *OrphanSweeper) retryPendingCleanups(ctx context.Context, nodeOrphans map[string][]*v1.PersistentVolumeClaim) map[string]struct{} {
+	pending := make(map[string]struct{})
+	for nodeName, missingSince := range s.missingSince {
+		if _, missing := nodeOrphans[nodeName]; missing {
+			continue
+		}
+
+		termination := nodeTermination{NodeName: nodeName, Source: TerminationSourceOrphanSweep, Time: missingSince}
+		if err := s.Reconciler.retryPendingCleanups(ctx, termination); err != nil {
+			pending[nodeName] = struct{}{}
+		}
+	}
+
+	return pending
+}
+
+// release releases the orphan PVCs of a single nonexistent node, returning the number of PVCs handed over for release
+func (s *OrphanSweeper) release(ctx context.Context, nodeName string, pvcs []*v1.PersistentVolumeClaim, missingSince time.Time) int {
+	r := s.Reconciler
+
+	pvcListPendingDeletion, err := r.selectReleasablePVCs(ctx, pvcs)
+	if err != nil {
+		r.Logger.Error(err, fmt.Sprintf("failed to classify the orphan pvc objects of node - %s", nodeName))
+	}
+	if len(pvcListPendingDeletion) == 0 {
+		return 0
+	}
+
+	dryrun := s.DryRun || r.DryRun
+	r.Collector.OrphanPVCs.With(prometheus.Labels{"dryrun": strconv.FormatBool(dryrun)}).Add(float64(len(pvcListPendingDeletion)))
+
+	if s.DryRun {
+		for _, pvc := range pvcListPendingDeletion {
+			r.Logger.Info(fmt.Sprintf("orphan pvc - %s/%s is pinned to the nonexistent node - %s and would be released (sweep dry-run)", pvc.Namespace, pvc.Name, nodeName))
+		}
+		return len(pvcListPendingDeletion)
+	}
+
+	// A startup sweep after an outage may find many nodes missing at once, so each node counts against the breaker limits
+	if r.Breaker != nil && !r.DryRun {
+		admitted, err := r.Breaker.AdmitNode(ctx, nodeName)
+		if err != nil {
+			r.Logger.Error(err, fmt.Sprintf("failed to admit the release of nonexistent node - %s orphan pvc objects", nodeName))
+			return 0
+		}
+		if !admitted {
+			r.Logger.Info(fmt.Sprintf("circuit breaker is open, release of nonexistent node - %s orphan pvc objects is held until the next sweep", nodeName))
+			return 0
+		}
+	}
+
+	termination := nodeTermination{NodeName: nodeName, Source: TerminationSourceOrphanSweep, Time: missingSince}
+	if _, err := r.CleanPVCS(ctx, pvcListPendingDeletion, termination); err != nil {
+		r.Logger.Error(err, fmt.Sprintf("failed to delete the orphan pvc objects of node - %s from kubernetes", nodeName))
+	}
+
+	return len(pvcListPendingDeletion)
+}
+
+// FindOrphanPVCs returns the PVCs carrying the selected-node annotation of a node that does not exist anymore
+func (s *OrphanSweeper) FindOrphanPVCs(ctx context.Context) ([]*v1.PersistentVolumeClaim, error) {
+	r := s.Reconciler
+
+	pvcList := &v1.PersistentVolumeClaimList{}
+	if err := r.List(ctx, pvcList); err != nil {
+		return nil, err
+	}
+
+	nodeExists := make(map[string]bool)
+	var orphans []*v1.PersistentVolumeClaim
+
+	for i := 0; i < len(pvcList.Items); i++ {
+		pvc := &pvcList.Items[i]
+
+		nodeName, annotated := pvc.Annotations[PVCnodeAnnotationKey]
+		if !annotated || nodeName == "" || pvc.DeletionTimestamp != nil {
+			continue
+		}
+
+		exists, checked := nodeExists[nodeName]
+		if !checked {
+			err := r.Get(ctx, client.ObjectKey{Name: nodeName}, &v1.Node{})
+			if err != nil && !apierrors.IsNotFound(err) {
+				return nil, err
+			}
+			exists = err == nil
+			nodeExists[nodeName] = exists
+		}
+
+		if !exists {
+			r.Logger.Info(fmt.Sprintf("pvc - %s/%s is pinned to the nonexistent node - %s", pvc.Namespace, pvc.Name, nodeName))
+			orphans = append(orphans, pvc)
+		}
+	}
+
+	return orphans, nil
+}
@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	"github.com/AppsFlyer/local-pvc-releaser/internal/breaker"
+)
+
+func TestOrphanSweeperWaitsForReleaseDelay(t *testing.T) {
+	r := newTestReconciler(t, interceptor.Funcs{}, testPVC("data-0", "pv-0"), testLocalPV("pv-0"))
+	r.ReleaseDelay = 10 * time.Minute
+	s := &OrphanSweeper{Reconciler: r}
+
+	s.sweep(context.Background())
+	assert.True(t, pvcExists(t, r, "data-0"))
+	require.Contains(t, s.missingSince, testNode)
+
+	// The node has been missing for longer than the grace period since the first sweep that found it
+	s.missingSince[testNode] = time.Now().Add(-11 * time.Minute)
+	s.sweep(context.Background())
+	assert.False(t, pvcExists(t, r, "data-0"))
+}
+
+func TestOrphanSweeperForgetsReturnedNode(t *testing.T) {
+	r := newTestReconciler(t, interceptor.Funcs{}, testPVC("data-0", "pv-0"), testLocalPV("pv-0"))
+	r.ReleaseDelay = 10 * time.Minute
+	s := &OrphanSweeper{Reconciler: r}
+
+	s.sweep(context.Background())
+	require.Contains(t, s.missingSince, testNode)
+	s.missingSince[testNode] = time.Now().Add(-11 * time.Minute)
+
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: testNode}}
+	require.NoError(t, r.Create(context.Background(), node))
+	s.sweep(context.Background())
+	assert.True(t, pvcExists(t, r, "data-0"))
+	assert.NotContains(t, s.missingSince, testNode)
+
+	// The node is removed again, the grace period starts over
+	require.NoError(t, r.Delete(context.Background(), node))
+	s.sweep(context.Background())
+	assert.True(t, pvcExists(t, r, "data-0"))
+	assert.WithinDuration(t, time.Now(), s.missingSince[testNode], time.Minute)
+}
+
+func TestOrphanSweeperDryRun(t *testing.T) {
+	r := newTestReconciler(t, interceptor.Funcs{}, testPVC("data-0", "pv-0"), testLocalPV("pv-0"))
+	s := &OrphanSweeper{Reconciler: r, DryRun: true}
+
+	s.sweep(context.Background())
+	assert.True(t, pvcExists(t, r, "data-0"))
+}
+
+func TestOrphanSweeperAdmitsNodesThroughTheCircuitBreaker(t *testing.T) {
+	otherNodePVC := testPVC("data-1", "pv-1")
+	otherNodePVC.Annotations[PVCnodeAnnotationKey] = "node-2"
+	r := newTestReconciler(t, interceptor.Funcs{},
+		testPVC("data-0", "pv-0"), testLocalPV("pv-0"),
+		otherNodePVC, testLocalPV("pv-1"),
+	)
+	b := withTestBreaker(r, breaker.Limits{MaxNodesPerWindow: 1, NodeWindow: time.Hour})
+	s := &OrphanSweeper{Reconciler: r}
+
+	s.sweep(context.Background())
+
+	// Only one of the missing nodes is released before the circuit breaker trips
+	assert.NotEqual(t, pvcExists(t, r, "data-0"), pvcExists(t, r, "data-1"))
+	assert.Equal(t, circuitBreakerOpen, breakerState(t, b))
+}
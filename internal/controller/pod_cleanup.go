@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// forceDeleteStuckPods force deletes the pods left on a removed node that reference the released PVC.
+// As long as such a pod object exists, the kubernetes.io/pvc-protection finalizer keeps the PVC in Terminating.
+func (r *PVCReconciler) forceDeleteStuckPods(ctx context.Context, pvc *v1.PersistentVolumeClaim) error {
+	podList := &v1.PodList{}
+	if err := r.List(ctx, podList, client.InNamespace(pvc.Namespace)); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to list the pods of object - %s,", pvc.GetName()))
+	}
+
+	dryrun := r.DryRun || r.ForceDeletePodsDryRun
+
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+
+		if !podReferencesPVC(pod, pvc.Name) {
+			continue
+		}
+
+		removed, err := r.nodeRemoved(ctx, pod.Spec.NodeName)
+		if err != nil {
+			return err
+		}
+		if !removed {
+			r.Logger.Info(fmt.Sprintf("pod - %s referencing pvc - %s is not scheduled to a removed node and will be skipped", pod.Name, pvc.Name))
+			continue
+		}
+
+		deleteOpts := []client.DeleteOption{client.GracePeriodSeconds(0)}
+		if dryrun && !r.DryRun {
+			deleteOpts = append(deleteOpts, client.DryRunAll)
+		}
+
+		if err := r.Delete(ctx, pod, deleteOpts...); err != nil && !apierrors.IsNotFound(err) {
+			r.Collector.ForceDeletePodFailures.Inc()
+			return errors.Wrap(err, fmt.Sprintf("failed to force delete pod - %s,", pod.GetName()))
+		}
+
+		if dryrun {
+			r.Recorder.Eventf(pod, "Normal", "Pod-Force-Delete-DryRun", "The Pod %s on removed node %s would have been force deleted to release PersistentVolumeClaim %s", pod.Name, pod.Spec.NodeName, pvc.Name)
+		} else {
+			r.Recorder.Eventf(pod, "Normal", "Pod-Force-Deleted", "The Pod %s on removed node %s was force deleted to release PersistentVolumeClaim %s", pod.Name, pod.Spec.NodeName, pvc.Name)
+		}
+		r.Collector.ForceDeletedPods.With(prometheus.Labels{"dryrun": strconv.FormatBool(dryrun)}).Inc()
+
+		r.Logger.Info(fmt.Sprintf("pod object - %s on removed node - %s was force deleted", pod.GetName(), pod.Spec.NodeName), "dryrun", dryrun)
+	}
+
+	return nil
+}
+
+// nodeRemoved reports whether the node no longer exists. The node the PVC was released for is verified as well,
+// as a node re-created with the same name runs the pods scheduled to it again.
+func (r *PVCReconciler) nodeRemoved(ctx context.Context, nodeName string) (bool, error) {
+	if nodeName == "" {
+		return false, nil
+	}
+
+	node := &v1.Node{}
+	if err := r.Get(ctx, types.NamespacedName{Name: nodeName}, node); err != nil {
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+
+	return false, nil
+}
+
+func podReferencesPVC(pod *v1.Pod, pvcName string) bool {
+	for _, volume := range pod.Spec.Volumes {
+		if volume.PersistentVolumeClaim != nil && volume.PersistentVolumeClaim.ClaimName == pvcName {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func testPod(name, nodeName, claimName string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: v1.PodSpec{
+			NodeName: nodeName,
+			Volumes: []v1.Volume{{
+				Name:         "data",
+				VolumeSource: v1.VolumeSource{PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: claimName}},
+			}},
+		},
+	}
+}
+
+func podExists(t *testing.T, r *PVCReconciler, name string) bool {
+	t.Helper()
+
+	err := r.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: name}, &v1.Pod{})
+	if apierrors.IsNotFound(err) {
+		return false
+	}
+	require.NoError(t, err)
+
+	return true
+}
+
+func TestForceDeleteStuckPods(t *testing.T) {
+	pvc := testPVC("data-0", "pv-0")
+	r := newTestReconciler(t, interceptor.Funcs{},
+		testPod("stuck", testNode, "data-0"),
+		testPod("other-claim", testNode, "data-1"),
+	)
+
+	require.NoError(t, r.forceDeleteStuckPods(context.Background(), pvc))
+	assert.False(t, podExists(t, r, "stuck"))
+	assert.True(t, podExists(t, r, "other-claim"))
+}
+
+func TestForceDeleteStuckPodsSkipsRecreatedNode(t *testing.T) {
+	pvc := testPVC("data-0", "pv-0")
+	r := newTestReconciler(t, interceptor.Funcs{},
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: testNode, UID: "new-node-uid"}},
+		testPod("running", testNode, "data-0"),
+	)
+
+	require.NoError(t, r.forceDeleteStuckPods(context.Background(), pvc))
+	assert.True(t, podExists(t, r, "running"))
+}
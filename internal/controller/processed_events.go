@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	processedEventKeyPrefix = "event-"
+	processedNodeKeyPrefix  = "node-"
+)
+
+// ProcessedEvents persists the handled node terminations in a ConfigMap, keyed by the event UID and the node UID.
+// Replayed events after a restart or a leader failover are then recognized, so each termination is handled exactly once
+// across the controller replicas. Records older than the retention are pruned.
+type ProcessedEvents struct {
+	Client    client.Client
+	Reader    client.Reader
+	Namespace string
+	Name      string
+	Retention time.Duration
+}
+
+// IsProcessed reports whether the termination event, or the termination of the same node, was already handled
+func (p *ProcessedEvents) IsProcessed(ctx context.Context, termination nodeTermination) (bool, error) {
+	configMap := &v1.ConfigMap{}
+	if err := p.Reader.Get(ctx, client.ObjectKey{Namespace: p.Namespace, Name: p.Name}, configMap); err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+
+	for _, key := range processedKeys(termination) {
+		if _, exists := configMap.Data[key]; exists {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// MarkProcessed records the termination as handled
+func (p *ProcessedEvents) MarkProcessed(ctx context.Context, termination nodeTermination) error {
+	keys := processedKeys(termination)
+	if len(keys) == 0 {
+		return nil
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMap := &v1.ConfigMap{}
+		err := p.Reader.Get(ctx, client.ObjectKey{Namespace: p.Namespace, Name: p.Name}, configMap)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+
+		exists := err == nil
+		if !exists {
+			configMap = &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: p.Namespace,
+					Name:      p.Name,
+				},
+			}
+		}
+		if configMap.Data == nil {
+			configMap.Data = make(map[string]string)
+		}
+
+		now := time.Now().UTC()
+		p.prune(configMap, now)
+		for _, key := range keys {
+			configMap.Data[key] = now.Format(time.RFC3339)
+		}
+
+		if exists {
+			return p.Client.Update(ctx, configMap)
+		}
+		return p.Client.Create(ctx, configMap)
+	})
+}
+
+func (p *ProcessedEvents) prune(configMap *v1.ConfigMap, now time.Time) {
+	for key, value := range configMap.Data {
+		processedAt, err := time.Parse(time.RFC3339, value)
+		if err != nil || now.Sub(processedAt) > p.Retention {
+			delete(configMap.Data, key)
+		}
+	}
+}
+
+func processedKeys(termination nodeTermination) []string {
+	var keys []string
+	if termination.EventUID != "" {
+		keys = append(keys, processedEventKeyPrefix+string(termination.EventUID))
+	}
+	if termination.NodeUID != "" {
+		keys = append(keys, processedNodeKeyPrefix+string(termination.NodeUID))
+	}
+
+	return keys
+}
@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	"github.com/AppsFlyer/local-pvc-releaser/internal/triggers"
+)
+
+func newTestProcessedEvents(c client.Client) *ProcessedEvents {
+	return &ProcessedEvents{
+		Client:    c,
+		Reader:    c,
+		Namespace: "local-pvc-releaser",
+		Name:      "processed-events",
+		Retention: time.Hour,
+	}
+}
+
+func processedRecords(t *testing.T, p *ProcessedEvents) map[string]string {
+	t.Helper()
+
+	configMap := &v1.ConfigMap{}
+	require.NoError(t, p.Reader.Get(context.Background(), client.ObjectKey{Namespace: p.Namespace, Name: p.Name}, configMap))
+
+	return configMap.Data
+}
+
+func TestProcessedEventsSkipReplayedEventAfterRestart(t *testing.T) {
+	removal := testTerminationEvent("removal", time.Now())
+	r := newTestReconciler(t, interceptor.Funcs{}, removal, testPVC("data-0", "pv-0"), testLocalPV("pv-0"))
+	r.Triggers, _ = triggers.NewRuleSet(nil)
+	r.ProcessedEvents = newTestProcessedEvents(r.Client)
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(removal)}
+
+	_, err := r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	assert.False(t, pvcExists(t, r, "data-0"))
+
+	// The restarted controller starts with an empty tracker and a store rebuilt from the same ConfigMap
+	r.tracker = newTerminationTracker(0)
+	r.ProcessedEvents = newTestProcessedEvents(r.Client)
+	require.NoError(t, r.Create(context.Background(), testPVC("data-1", "pv-1")))
+	require.NoError(t, r.Create(context.Background(), testLocalPV("pv-1")))
+
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	assert.True(t, pvcExists(t, r, "data-1"))
+	assert.True(t, r.tracker.IsReleased(nodeTermination{NodeName: testNode, NodeUID: "node-uid"}))
+}
+
+func TestProcessedEventsMatching(t *testing.T) {
+	r := newTestReconciler(t, interceptor.Funcs{})
+	p := newTestProcessedEvents(r.Client)
+	ctx := context.Background()
+
+	// The ConfigMap is created by the first record and updated by the next ones
+	require.NoError(t, p.MarkProcessed(ctx, nodeTermination{NodeName: testNode, EventUID: "event-0"}))
+	require.NoError(t, p.MarkProcessed(ctx, nodeTermination{NodeName: testNode, NodeUID: "node-uid"}))
+	assert.Len(t, processedRecords(t, p), 2)
+
+	tests := []struct {
+		name        string
+		termination nodeTermination
+		processed   bool
+	}{
+		{
+			name:        "same event",
+			termination: nodeTermination{NodeName: testNode, EventUID: "event-0"},
+			processed:   true,
+		},
+		{
+			name:        "another event of the same node",
+			termination: nodeTermination{NodeName: testNode, NodeUID: "node-uid", EventUID: "event-1"},
+			processed:   true,
+		},
+		{
+			name:        "node re-created with the same name",
+			termination: nodeTermination{NodeName: testNode, NodeUID: "node-uid-2", EventUID: "event-2"},
+			processed:   false,
+		},
+		{
+			name:        "termination without uids",
+			termination: nodeTermination{NodeName: testNode},
+			processed:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processed, err := p.IsProcessed(ctx, tt.termination)
+			require.NoError(t, err)
+			assert.Equal(t, tt.processed, processed)
+		})
+	}
+}
+
+func TestProcessedEventsNotFound(t *testing.T) {
+	r := newTestReconciler(t, interceptor.Funcs{})
+	p := newTestProcessedEvents(r.Client)
+
+	processed, err := p.IsProcessed(context.Background(), nodeTermination{NodeName: testNode, EventUID: "event-0"})
+	require.NoError(t, err)
+	assert.False(t, processed)
+}
+
+func TestProcessedEventsPruneExpiredRecords(t *testing.T) {
+	p := &ProcessedEvents{Namespace: "local-pvc-releaser", Name: "processed-events", Retention: time.Hour}
+	configMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: p.Namespace, Name: p.Name},
+		Data: map[string]string{
+			processedEventKeyPrefix + "expired": time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339),
+			processedEventKeyPrefix + "invalid": "yesterday",
+			processedNodeKeyPrefix + "recent":   time.Now().Add(-time.Minute).UTC().Format(time.RFC3339),
+		},
+	}
+	r := newTestReconciler(t, interceptor.Funcs{}, configMap)
+	p.Client, p.Reader = r.Client, r.Client
+
+	require.NoError(t, p.MarkProcessed(context.Background(), nodeTermination{NodeName: testNode, EventUID: "event-0"}))
+
+	records := processedRecords(t, p)
+	assert.NotContains(t, records, processedEventKeyPrefix+"expired")
+	assert.NotContains(t, records, processedEventKeyPrefix+"invalid")
+	assert.Contains(t, records, processedNodeKeyPrefix+"recent")
+	assert.Contains(t, records, processedEventKeyPrefix+"event-0")
+}
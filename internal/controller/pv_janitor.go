@@ -0,0 +1,191 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/AppsFlyer/local-pvc-releaser/internal/classifier"
+	"github.com/AppsFlyer/local-pvc-releaser/internal/exporters"
+)
+
+// Actions of the PV janitor on the orphaned PVs of released PVCs
+const (
+	PVJanitorActionDelete  = "delete"
+	PVJanitorActionRecycle = "recycle"
+)
+
+const pvJanitorCheckInterval = 30 * time.Second
+
+// orphanedPV is the PV of a released PVC, followed until its claim is gone
+type orphanedPV struct {
+	Name     string
+	ClaimUID types.UID
+	NodeName string
+	DryRun   bool
+}
+
+// PVJanitor cleans up the node-local PVs left behind by the released PVCs. Under a Retain reclaim policy such a PV
+// stays Released or Failed forever, and its node affinity pins it to the removed node.
+// Once the claim is gone and the node no longer exists, the PV is either deleted or recycled by stripping its ClaimRef.
+// The PV is handled with the dry-run setting its PVC was released with.
+// The PVs are followed in memory only, a restart or a leader change forgets the PVs of the releases it performed.
+type PVJanitor struct {
+	Client          client.Client
+	Recorder        record.EventRecorder
+	Collector       *exporters.Collector
+	Logger          *logr.Logger
+	Action          string
+	NodeTopologyKey string
+	DryRun          bool
+
+	leaderOnly
+	orphaned trackedEntries[string, *orphanedPV]
+}
+
+// Track starts following the PV of the released PVC
+func (j *PVJanitor) Track(pvc *v1.PersistentVolumeClaim, dryrun bool) {
+	if pvc.Spec.VolumeName == "" {
+		return
+	}
+
+	j.orphaned.track(pvc.Spec.VolumeName, &orphanedPV{
+		Name:     pvc.Spec.VolumeName,
+		ClaimUID: pvc.UID,
+		NodeName: pvc.Annotations[PVCnodeAnnotationKey],
+		DryRun:   dryrun,
+	})
+}
+
+// Start implements manager.Runnable
+func (j *PVJanitor) Start(ctx context.Context) error {
+	return runEvery(ctx, pvJanitorCheckInterval, j.check)
+}
+
+func (j *PVJanitor) check(ctx context.Context) {
+	j.orphaned.checkEach(ctx, func(ctx context.Context, orphan *orphanedPV) bool {
+		done, err := j.clean(ctx, orphan)
+		if err != nil {
+			j.Logger.Error(err, fmt.Sprintf("failed to clean up pv - %s", orphan.Name))
+		}
+		return done
+	}, func(orphan *orphanedPV) {
+		j.Logger.Info(fmt.Sprintf("pv - %s claim was not removed within %s and is no longer tracked", orphan.Name, trackingRetention))
+	})
+}
+
+// clean handles the orphaned PV once its claim is gone, it reports whether the PV no longer needs to be tracked
+func (j *PVJanitor) clean(ctx context.Context, orphan *orphanedPV) (bool, error) {
+	pv := &v1.PersistentVolume{}
+	if err := j.Client.Get(ctx, client.ObjectKey{Name: orphan.Name}, pv); err != nil {
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+
+	if pv.DeletionTimestamp != nil || pv.Spec.ClaimRef == nil || pv.Spec.ClaimRef.UID != orphan.ClaimUID {
+		j.Logger.Info(fmt.Sprintf("pv - %s is no longer claimed by the released pvc and will be skipped", pv.Name))
+		return true, nil
+	}
+
+	// A dry-run release never removes the claim, so the PV is reported right away
+	if !orphan.DryRun {
+		switch pv.Status.Phase {
+		case v1.VolumeReleased:
+			if pv.Spec.PersistentVolumeReclaimPolicy != v1.PersistentVolumeReclaimRetain {
+				j.Logger.Info(fmt.Sprintf("pv - %s is reclaimed by its %s reclaim policy and will be skipped", pv.Name, pv.Spec.PersistentVolumeReclaimPolicy))
+				return true, nil
+			}
+		case v1.VolumeFailed:
+		default:
+			return false, nil
+		}
+	}
+
+	nodeName := orphan.NodeName
+	if pinnedNode, pinned := classifier.PinnedNode(pv, j.NodeTopologyKey); pinned {
+		nodeName = pinnedNode
+	}
+	if nodeName == "" {
+		j.Logger.Info(fmt.Sprintf("pv - %s is not pinned to a node and will be skipped", pv.Name))
+		return true, nil
+	}
+
+	if err := j.Client.Get(ctx, types.NamespacedName{Name: nodeName}, &v1.Node{}); err == nil {
+		j.Logger.Info(fmt.Sprintf("pv - %s node - %s exists and the pv will be skipped", pv.Name, nodeName))
+		return true, nil
+	} else if !apierrors.IsNotFound(err) {
+		return false, err
+	}
+
+	dryrun := orphan.DryRun || j.DryRun
+	var err error
+	if j.Action == PVJanitorActionRecycle {
+		err = j.recycle(ctx, pv, dryrun && !j.DryRun)
+	} else {
+		err = j.delete(ctx, pv, dryrun && !j.DryRun)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	j.recordCleaned(pv, nodeName, dryrun)
+
+	return true, nil
+}
+
+func (j *PVJanitor) delete(ctx context.Context, pv *v1.PersistentVolume, serverDryRun bool) error {
+	deleteOpts := []client.DeleteOption{client.Preconditions{UID: &pv.UID, ResourceVersion: &pv.ResourceVersion}}
+	if serverDryRun {
+		deleteOpts = append(deleteOpts, client.DryRunAll)
+	}
+
+	if err := j.Client.Delete(ctx, pv, deleteOpts...); err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, fmt.Sprintf("failed to delete pv - %s,", pv.GetName()))
+	}
+
+	return nil
+}
+
+// recycle strips the ClaimRef of the PV, making it Available again for a node returning with the same name
+func (j *PVJanitor) recycle(ctx context.Context, pv *v1.PersistentVolume, serverDryRun bool) error {
+	patchOpts := []client.PatchOption{}
+	if serverDryRun {
+		patchOpts = append(patchOpts, client.DryRunAll)
+	}
+
+	patch := client.MergeFromWithOptions(pv.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	pv.Spec.ClaimRef = nil
+	if err := j.Client.Patch(ctx, pv, patch, patchOpts...); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to strip the claim of pv - %s,", pv.GetName()))
+	}
+
+	return nil
+}
+
+func (j *PVJanitor) recordCleaned(pv *v1.PersistentVolume, nodeName string, dryrun bool) {
+	switch {
+	case j.Action == PVJanitorActionRecycle && dryrun:
+		j.Recorder.Eventf(pv, "Normal", "PV-Recycle-DryRun", "The PersistentVolume %s of removed node %s would have been recycled", pv.Name, nodeName)
+	case j.Action == PVJanitorActionRecycle:
+		j.Recorder.Eventf(pv, "Normal", "PV-Recycled", "The PersistentVolume %s of removed node %s was recycled", pv.Name, nodeName)
+	case dryrun:
+		j.Recorder.Eventf(pv, "Normal", "PV-Delete-DryRun", "The PersistentVolume %s of removed node %s would have been deleted", pv.Name, nodeName)
+	default:
+		j.Recorder.Eventf(pv, "Normal", "PV-Deleted", "The PersistentVolume %s of removed node %s was deleted", pv.Name, nodeName)
+	}
+	j.Collector.CleanedPV.With(prometheus.Labels{"action": j.Action, "dryrun": strconv.FormatBool(dryrun)}).Inc()
+
+	j.Logger.Info(fmt.Sprintf("pv object - %s of removed node - %s was cleaned up", pv.GetName(), nodeName), "action", j.Action, "dryrun", dryrun)
+}
@@ -0,0 +1,152 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+// orphanedLocalPV returns the Released PV of the data-0 test PVC under a Retain reclaim policy
+func orphanedLocalPV() *v1.PersistentVolume {
+	pv := testLocalPV("pv-0")
+	pv.Spec.PersistentVolumeReclaimPolicy = v1.PersistentVolumeReclaimRetain
+	pv.Spec.ClaimRef = &v1.ObjectReference{Namespace: "default", Name: "data-0", UID: "data-0"}
+	pv.Status.Phase = v1.VolumeReleased
+
+	return pv
+}
+
+func TestPVJanitor(t *testing.T) {
+	tests := []struct {
+		name    string
+		action  string
+		dryrun  bool
+		mutate  func(pv *v1.PersistentVolume)
+		objs    []client.Object
+		tracked bool
+		deleted bool
+		claimed bool
+		cleaned string
+	}{
+		{
+			name:    "delete",
+			action:  PVJanitorActionDelete,
+			deleted: true,
+			cleaned: "false",
+		},
+		{
+			name:    "recycle",
+			action:  PVJanitorActionRecycle,
+			cleaned: "false",
+		},
+		{
+			name:    "failed pv",
+			action:  PVJanitorActionDelete,
+			mutate:  func(pv *v1.PersistentVolume) { pv.Status.Phase = v1.VolumeFailed },
+			deleted: true,
+			cleaned: "false",
+		},
+		{
+			name:   "reclaimed by its reclaim policy",
+			action: PVJanitorActionDelete,
+			mutate: func(pv *v1.PersistentVolume) {
+				pv.Spec.PersistentVolumeReclaimPolicy = v1.PersistentVolumeReclaimDelete
+			},
+			claimed: true,
+		},
+		{
+			name:    "claim not removed yet",
+			action:  PVJanitorActionDelete,
+			mutate:  func(pv *v1.PersistentVolume) { pv.Status.Phase = v1.VolumeBound },
+			tracked: true,
+			claimed: true,
+		},
+		{
+			name:    "claimed by another pvc",
+			action:  PVJanitorActionDelete,
+			mutate:  func(pv *v1.PersistentVolume) { pv.Spec.ClaimRef.UID = "data-0-replacement" },
+			claimed: true,
+		},
+		{
+			name:    "node still exists",
+			action:  PVJanitorActionDelete,
+			objs:    []client.Object{&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: testNode}}},
+			claimed: true,
+		},
+		{
+			name:    "dry-run delete",
+			action:  PVJanitorActionDelete,
+			dryrun:  true,
+			mutate:  func(pv *v1.PersistentVolume) { pv.Status.Phase = v1.VolumeBound },
+			claimed: true,
+			cleaned: "true",
+		},
+		{
+			name:    "dry-run recycle",
+			action:  PVJanitorActionRecycle,
+			dryrun:  true,
+			mutate:  func(pv *v1.PersistentVolume) { pv.Status.Phase = v1.VolumeBound },
+			claimed: true,
+			cleaned: "true",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pv := orphanedLocalPV()
+			if tt.mutate != nil {
+				tt.mutate(pv)
+			}
+			// A dry-run release never deletes or patches the PV for real
+			funcs := interceptor.Funcs{
+				Delete: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+					deleteOpts := &client.DeleteOptions{}
+					deleteOpts.ApplyOptions(opts)
+					assert.False(t, tt.dryrun && len(deleteOpts.DryRun) == 0, "pv deleted by a dry-run release")
+					return c.Delete(ctx, obj, opts...)
+				},
+				Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+					patchOpts := &client.PatchOptions{}
+					patchOpts.ApplyOptions(opts)
+					assert.False(t, tt.dryrun && len(patchOpts.DryRun) == 0, "pv patched by a dry-run release")
+					return c.Patch(ctx, obj, patch, opts...)
+				},
+			}
+			r := newTestReconciler(t, funcs, append(tt.objs, pv)...)
+			janitor := &PVJanitor{Client: r.Client, Recorder: r.Recorder, Collector: r.Collector, Logger: r.Logger, Action: tt.action}
+
+			janitor.Track(testPVC("data-0", "pv-0"), tt.dryrun)
+			janitor.check(context.Background())
+
+			if tt.tracked {
+				assert.Equal(t, 1, janitor.orphaned.len())
+			} else {
+				assert.Equal(t, 0, janitor.orphaned.len())
+			}
+
+			current := &v1.PersistentVolume{}
+			err := r.Get(context.Background(), client.ObjectKey{Name: "pv-0"}, current)
+			if tt.deleted {
+				assert.True(t, apierrors.IsNotFound(err))
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.claimed, current.Spec.ClaimRef != nil)
+			}
+
+			if tt.cleaned == "" {
+				assert.Equal(t, 0, testutil.CollectAndCount(r.Collector.CleanedPV))
+			} else {
+				assert.Equal(t, 1.0, testutil.ToFloat64(r.Collector.CleanedPV.With(prometheus.Labels{"action": tt.action, "dryrun": tt.cleaned})))
+			}
+		})
+	}
+}
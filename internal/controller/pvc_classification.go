@@ -0,0 +1,188 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/AppsFlyer/local-pvc-releaser/internal/exporters"
+)
+
+// Policies for PVCs whose bound PV no longer exists
+const (
+	MissingPVPolicySkip   = "skip"
+	MissingPVPolicyDelete = "delete"
+)
+
+// Actions for unbound PVCs whose selected node is gone
+const (
+	UnboundPVCActionReschedule = "reschedule"
+	UnboundPVCActionDelete     = "delete"
+	UnboundPVCActionSkip       = "skip"
+)
+
+// pvcClass classifies a PVC of a removed node by the state of its volume
+type pvcClass int
+
+const (
+	pvcLocal pvcClass = iota
+	pvcNotLocal
+	// pvcUnbound is a Pending PVC the scheduler selected the node for, before its volume was provisioned
+	pvcUnbound
+	// pvcPVMissing is a PVC whose bound PV was already removed
+	pvcPVMissing
+)
+
+func (r *PVCReconciler) classifyPVC(ctx context.Context, pvc *v1.PersistentVolumeClaim) (pvcClass, error) {
+	if pvc.Spec.VolumeName == "" {
+		return pvcUnbound, nil
+	}
+
+	err, isLocal := r.CheckLocalPvStoragePluginByPVC(ctx, pvc)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return pvcPVMissing, nil
+		}
+		return pvcNotLocal, err
+	}
+
+	if !isLocal {
+		return pvcNotLocal, nil
+	}
+
+	return pvcLocal, nil
+}
+
+// selectReleasablePVCs classifies the PVCs of a removed node and returns the ones to release.
+// Unbound PVCs are handled by the unbound PVC action, and PVCs of a missing PV by the missing PV policy.
+// Unbound PVCs to reschedule are returned as well, so CleanPVCS applies the same selector, policies and dry-run to them.
+// A PVC failing the classification does not stop the others, the failures are returned aggregated.
+func (r *PVCReconciler) selectReleasablePVCs(ctx context.Context, pvcs []*v1.PersistentVolumeClaim) ([]*v1.PersistentVolumeClaim, error) {
+	var errs []error
+	releasable := make([]*v1.PersistentVolumeClaim, 0, len(pvcs))
+
+	for _, pvc := range pvcs {
+		if pvc.DeletionTimestamp != nil {
+			r.Logger.Info(fmt.Sprintf("pvc - %s is already being deleted and will be skipped", pvc.Name))
+			continue
+		}
+
+		r.recordEvaluated(pvc)
+
+		class, err := r.classifyPVC(ctx, pvc)
+		if err != nil {
+			errs = append(errs, &pvcReleaseError{PVC: pvc, err: errors.Wrap(err, fmt.Sprintf("failed to classify object - %s,", pvc.GetName()))})
+			continue
+		}
+
+		switch class {
+		case pvcUnbound:
+			switch r.UnboundPVCAction {
+			case UnboundPVCActionDelete:
+				r.Logger.Info(fmt.Sprintf("pvc - %s is not bound to any pv and will be marked for deletion", pvc.Name))
+				releasable = append(releasable, pvc)
+			case UnboundPVCActionReschedule:
+				r.Logger.Info(fmt.Sprintf("pvc - %s is not bound to any pv and will be marked for rescheduling", pvc.Name))
+				releasable = append(releasable, pvc)
+			default:
+				r.Logger.Info(fmt.Sprintf("pvc - %s is not bound to any pv and will be skipped", pvc.Name))
+				r.recordSkipped(pvc, exporters.SkipReasonUnbound)
+			}
+		case pvcPVMissing:
+			if r.MissingPVPolicy != MissingPVPolicyDelete {
+				r.Logger.Info(fmt.Sprintf("pv - %s of pvc - %s no longer exists and the pvc will be skipped", pvc.Spec.VolumeName, pvc.Name))
+				r.recordSkipped(pvc, exporters.SkipReasonPVMissing)
+				continue
+			}
+			r.Logger.Info(fmt.Sprintf("pv - %s of pvc - %s no longer exists and the pvc will be marked for deletion", pvc.Spec.VolumeName, pvc.Name))
+			releasable = append(releasable, pvc)
+		case pvcNotLocal:
+			r.recordSkipped(pvc, exporters.SkipReasonNotLocal)
+		case pvcLocal:
+			r.Logger.Info(fmt.Sprintf("pvc - %s is bounded to a pv with local storage on the terminated node and will be marked for deletion", pvc.Name))
+			releasable = append(releasable, pvc)
+		}
+	}
+
+	return releasable, utilerrors.NewAggregate(errs)
+}
+
+// reschedules reports whether the PVC is rescheduled by CleanPVCS instead of being deleted
+func (r *PVCReconciler) reschedules(pvc *v1.PersistentVolumeClaim) bool {
+	return pvc.Spec.VolumeName == "" && r.UnboundPVCAction == UnboundPVCActionReschedule
+}
+
+// reschedulePVC clears the stale selected-node annotation of an unbound WaitForFirstConsumer PVC whose selected node is gone,
+// so the scheduler picks a live node and the volume is provisioned there
+func (r *PVCReconciler) reschedulePVC(ctx context.Context, pvc *v1.PersistentVolumeClaim, dryrun bool) error {
+	nodeName := pvc.Annotations[PVCnodeAnnotationKey]
+	if nodeName == "" {
+		return nil
+	}
+
+	waitForFirstConsumer, err := r.waitsForFirstConsumer(ctx, pvc)
+	if err != nil {
+		return err
+	}
+	if !waitForFirstConsumer {
+		r.Logger.Info(fmt.Sprintf("pvc - %s is not bound and its storage class does not wait for the first consumer, it will be skipped", pvc.Name))
+		r.recordSkipped(pvc, exporters.SkipReasonUnbound)
+		return nil
+	}
+
+	if err := r.Get(ctx, types.NamespacedName{Name: nodeName}, &v1.Node{}); err == nil {
+		r.Logger.Info(fmt.Sprintf("pvc - %s is not bound and its selected node - %s still exists, it will be skipped", pvc.Name, nodeName))
+		r.recordSkipped(pvc, exporters.SkipReasonUnbound)
+		return nil
+	} else if !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, fmt.Sprintf("failed to get the selected node of object - %s,", pvc.GetName()))
+	}
+
+	var patchOpts []client.PatchOption
+	if dryrun && !r.DryRun {
+		patchOpts = append(patchOpts, client.DryRunAll)
+	}
+
+	patch := client.MergeFrom(pvc.DeepCopy())
+	delete(pvc.Annotations, PVCnodeAnnotationKey)
+	if err := r.Patch(ctx, pvc, patch, patchOpts...); err != nil {
+		r.recordFailed(pvc)
+		return errors.Wrap(err, fmt.Sprintf("failed to clear the selected node of object - %s,", pvc.GetName()))
+	}
+
+	dryrun = dryrun || r.DryRun
+	if dryrun {
+		r.Recorder.Eventf(pvc, "Normal", "PVC-Reschedule-DryRun", "The PersistentVolumeClaim %s is not bound and its selected node %s is gone, the annotation would have been cleared so the scheduler can pick another node", pvc.Name, nodeName)
+	} else {
+		r.Recorder.Eventf(pvc, "Normal", "PVC-Rescheduled", "The PersistentVolumeClaim %s is not bound and its selected node %s is gone, the annotation was cleared so the scheduler can pick another node", pvc.Name, nodeName)
+	}
+	r.recordRescheduled(pvc, dryrun)
+
+	r.Logger.Info(fmt.Sprintf("pvc - %s is not bound and its stale selected node - %s was cleared", pvc.Name, nodeName), "dryrun", dryrun)
+
+	return nil
+}
+
+// waitsForFirstConsumer reports whether the storage class of the PVC delays the binding until a pod is scheduled
+func (r *PVCReconciler) waitsForFirstConsumer(ctx context.Context, pvc *v1.PersistentVolumeClaim) (bool, error) {
+	if pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName == "" {
+		return false, nil
+	}
+
+	storageClass := &storagev1.StorageClass{}
+	if err := r.Get(ctx, types.NamespacedName{Name: *pvc.Spec.StorageClassName}, storageClass); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, errors.Wrap(err, fmt.Sprintf("failed to get the storage class of object - %s,", pvc.GetName()))
+	}
+
+	return storageClass.VolumeBindingMode != nil && *storageClass.VolumeBindingMode == storagev1.VolumeBindingWaitForFirstConsumer, nil
+}
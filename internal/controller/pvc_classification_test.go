@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	releaserv1alpha1 "github.com/AppsFlyer/local-pvc-releaser/api/v1alpha1"
+)
+
+func waitForFirstConsumerClass() *storagev1.StorageClass {
+	mode := storagev1.VolumeBindingWaitForFirstConsumer
+
+	return &storagev1.StorageClass{
+		ObjectMeta:        metav1.ObjectMeta{Name: "local-storage"},
+		Provisioner:       "kubernetes.io/no-provisioner",
+		VolumeBindingMode: &mode,
+	}
+}
+
+func selectedNode(t *testing.T, r *PVCReconciler, name string) string {
+	t.Helper()
+
+	pvc := &v1.PersistentVolumeClaim{}
+	require.NoError(t, r.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: name}, pvc))
+
+	return pvc.Annotations[PVCnodeAnnotationKey]
+}
+
+func TestRescheduleAppliesTheAnnotationSelector(t *testing.T) {
+	optedIn := testPVC("opted-in", "")
+	optedIn.Annotations["appsflyer.com/local-pvc-releaser"] = "enabled"
+	r := newTestReconciler(t, interceptor.Funcs{}, waitForFirstConsumerClass(), optedIn, testPVC("not-opted-in", ""))
+	r.UnboundPVCAction = UnboundPVCActionReschedule
+	r.PvcSelector = true
+	r.PvcAnoCustomKey, r.PvcAnoCustomValue = "appsflyer.com/local-pvc-releaser", "enabled"
+
+	_, err := r.Reconcile(context.Background(), deleteNode(r))
+	require.NoError(t, err)
+
+	assert.Empty(t, selectedNode(t, r, "opted-in"))
+	assert.Equal(t, testNode, selectedNode(t, r, "not-opted-in"))
+}
+
+func TestRescheduleAppliesThePolicyDryRun(t *testing.T) {
+	policy := &releaserv1alpha1.ReleasePolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "dry-run"},
+		Spec:       releaserv1alpha1.ReleasePolicySpec{DryRun: true},
+	}
+	r := newTestReconciler(t, interceptor.Funcs{}, waitForFirstConsumerClass(), policy, testPVC("data-0", ""))
+	r.UnboundPVCAction = UnboundPVCActionReschedule
+
+	_, err := r.Reconcile(context.Background(), deleteNode(r))
+	require.NoError(t, err)
+
+	assert.Equal(t, testNode, selectedNode(t, r, "data-0"))
+}
+
+func TestMissingPVDeletionAppliesTheAnnotationSelector(t *testing.T) {
+	optedIn := testPVC("opted-in", "pv-missing-0")
+	optedIn.Annotations["appsflyer.com/local-pvc-releaser"] = "enabled"
+	r := newTestReconciler(t, interceptor.Funcs{}, optedIn, testPVC("not-opted-in", "pv-missing-1"))
+	r.MissingPVPolicy = MissingPVPolicyDelete
+	r.PvcSelector = true
+	r.PvcAnoCustomKey, r.PvcAnoCustomValue = "appsflyer.com/local-pvc-releaser", "enabled"
+
+	_, err := r.Reconcile(context.Background(), deleteNode(r))
+	require.NoError(t, err)
+
+	assert.False(t, pvcExists(t, r, "opted-in"))
+	assert.True(t, pvcExists(t, r, "not-opted-in"))
+}
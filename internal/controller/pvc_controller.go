@@ -18,27 +18,39 @@ package controller
 
 import (
 	"context"
+	stderrors "errors"
 	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 
+	"github.com/AppsFlyer/local-pvc-releaser/internal/classifier"
 	"github.com/AppsFlyer/local-pvc-releaser/internal/exporters"
+	"github.com/AppsFlyer/local-pvc-releaser/internal/triggers"
 
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 const (
-	RemovingNode            = "RemovingNode"
-	NodeControllerComponent = "node-controller"
+	RemovingNode            = triggers.RemovingNodeReason
+	NodeControllerComponent = triggers.NodeControllerComponent
 	PVCnodeAnnotationKey    = "volume.kubernetes.io/selected-node"
 )
 
@@ -53,78 +65,579 @@ type PVCReconciler struct {
 	PvcSelector       bool
 	PvcAnoCustomKey   string
 	PvcAnoCustomValue string
+	Triggers          *triggers.RuleSet
+	ReleaseDelay      time.Duration
+	Classifier        classifier.Classifier
+	Breaker           *CircuitBreaker
+	Recovery          *RecoveryTracker
+	PVJanitor         *PVJanitor
+	Reprovisioner     *PVCReprovisioner
+	ProcessedEvents   *ProcessedEvents
+
+	// MissingPVPolicy handles the PVCs whose bound PV no longer exists, either skipping or deleting them
+	MissingPVPolicy string
+	// UnboundPVCAction handles the unbound PVCs whose selected node is gone, either rescheduling, deleting or skipping them
+	UnboundPVCAction string
+
+	// MaxEventAge ignores termination events older than it, such as the ones replayed on restart, 0 is unlimited
+	MaxEventAge time.Duration
+
+	// ForceDeletePods force deletes the pods left on the removed node that reference a released PVC
+	ForceDeletePods       bool
+	ForceDeletePodsDryRun bool
+
+	// MaxConcurrentStatefulSetReleases limits the recovering replicas of every StatefulSet, 0 is unlimited
+	MaxConcurrentStatefulSetReleases int
+	// StatefulSetRecoveryTimeout is the time after which a replica that did not recover stops holding the releases, 0 never expires
+	StatefulSetRecoveryTimeout time.Duration
+
+	// CleanVolumeAttachments removes the VolumeAttachments of a released PVC volume left on the removed node
+	CleanVolumeAttachments bool
+
+	// PVNodeAffinityDiscovery finds PVCs through the node affinity of their PVs as well, for statically provisioned PVs
+	PVNodeAffinityDiscovery bool
+	NodeTopologyKey         string
+
+	tracker *terminationTracker
+	stsGate *statefulSetGate
 }
 
 // +kubebuilder:rbac:groups="",resources=events,verbs=list;get;create;watch
-// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch;delete
-// +kubebuilder:rbac:groups="",resources=persistentvolumes,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch;create;patch;delete
+// +kubebuilder:rbac:groups="",resources=persistentvolumes,verbs=get;list;watch;patch;delete
+// +kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;create;update
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;delete
+// +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=storage.k8s.io,resources=storageclasses,verbs=get;list;watch
+// +kubebuilder:rbac:groups=storage.k8s.io,resources=volumeattachments,verbs=get;list;watch;patch;delete
+// +kubebuilder:rbac:groups=releaser.appsflyer.com,resources=releasepolicies,verbs=get;list;watch
+// +kubebuilder:rbac:groups=releaser.appsflyer.com,resources=releasepolicies/status,verbs=get;update;patch
 
 func (r *PVCReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	nodeTerminationEvent := &v1.Event{}
-	if err := r.Get(ctx, req.NamespacedName, nodeTerminationEvent); err != nil {
-		r.Logger.Error(err, "did not find the related NodeTermination event")
+	termination, found, err := r.resolveTermination(ctx, req)
+	if err != nil || !found {
+		return ctrl.Result{}, err
+	}
 
+	if r.tracker.IsReleased(termination) {
+		r.Logger.Info(fmt.Sprintf("pvc objects of node - %s were already released, skipping %s trigger", termination.NodeName, termination.Source))
+		return ctrl.Result{}, nil
+	}
+
+	if r.ProcessedEvents != nil {
+		processed, err := r.ProcessedEvents.IsProcessed(ctx, termination)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if processed {
+			r.Logger.Info(fmt.Sprintf("termination of node - %s was already processed, skipping replayed %s trigger", termination.NodeName, termination.Source))
+			r.tracker.MarkReleased(termination)
+			return ctrl.Result{}, nil
+		}
+	}
+
+	if r.ReleaseDelay > 0 {
+		if remaining := time.Until(termination.Time.Add(r.ReleaseDelay)); remaining > 0 {
+			r.Logger.Info(fmt.Sprintf("release of node - %s pvc objects is delayed by the grace period", termination.NodeName), "RequeueAfter", remaining)
+			return ctrl.Result{RequeueAfter: remaining}, nil
+		}
+
+		returned, err := r.nodeReturned(ctx, termination)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		// A cancelled release is not marked as released, so a later deletion of the node is handled again
+		if returned {
+			r.tracker.Forget(termination)
+			return ctrl.Result{}, nil
+		}
+	}
+
+	// A refused release is not marked as released, so the deletion of the node triggers it again
+	gone, err := r.verifyNodeGone(ctx, termination)
+	if err != nil || !gone {
 		return ctrl.Result{}, err
 	}
 
-	r.Logger.Info("node termination event found", "Message", nodeTerminationEvent.Message, "EventID", nodeTerminationEvent.UID, "EventTime", nodeTerminationEvent.LastTimestamp)
+	terminatedNodeName := termination.NodeName
 
-	terminatedNodeName := nodeTerminationEvent.InvolvedObject.Name
+	// The circuit breaker state is never persisted under the global dry-run, so it does not count dry-run releases
+	if r.Breaker != nil && !r.DryRun {
+		admitted, err := r.Breaker.AdmitNode(ctx, terminatedNodeName)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if !admitted {
+			r.Logger.Info(fmt.Sprintf("circuit breaker is open, release of node - %s pvc objects is held", terminatedNodeName))
+			return ctrl.Result{RequeueAfter: circuitBreakerRecheckInterval}, nil
+		}
+	}
 
 	pvcList := &v1.PersistentVolumeClaimList{}
-	if err := r.List(ctx, pvcList); err != nil {
+	if err := r.List(ctx, pvcList, client.MatchingFields{PVCSelectedNodeIndex: terminatedNodeName}); err != nil {
 		return ctrl.Result{}, err
 	}
 
 	// Filtering the related PVC objects bounded to the terminated node
 	nodePvcList := r.FilterPVCListByNodeName(pvcList, terminatedNodeName)
 
+	if r.PVNodeAffinityDiscovery {
+		pvList := &v1.PersistentVolumeList{}
+		if err := r.List(ctx, pvList, client.MatchingFields{PVNodeAffinityIndex: terminatedNodeName}); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		affinityPvcList, err := r.FilterPVCListByPVNodeAffinity(ctx, pvList, terminatedNodeName)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		nodePvcList = mergePVCLists(nodePvcList, affinityPvcList)
+	}
+
 	if len(nodePvcList) == 0 {
 		r.Logger.Info(fmt.Sprintf("could not find any bounded pvc objects for node - %s. will not take any action", terminatedNodeName))
+		if err := r.retryPendingCleanups(ctx, termination); err != nil {
+			return ctrl.Result{}, err
+		}
+		r.markReleased(ctx, termination)
 		return ctrl.Result{}, nil
 	}
 
-	pvcListPendingDeletion := make([]*v1.PersistentVolumeClaim, 0)
-	for _, nodePvc := range nodePvcList {
-
-		err, isLocal := r.CheckLocalPvStoragePluginByPVC(ctx, nodePvc)
-		if err != nil {
-			return ctrl.Result{}, err
+	// A PVC failing the classification is retried with the failed releases, the rest of the node's PVCs are still released
+	pvcListPendingDeletion, classifyErr := r.selectReleasablePVCs(ctx, nodePvcList)
+
+	// A retry after failed releases attempts only the PVCs that failed
+	if failed := r.tracker.FailedPVCs(termination); failed != nil {
+		retried := make([]*v1.PersistentVolumeClaim, 0, len(failed))
+		for _, pvc := range pvcListPendingDeletion {
+			if _, exists := failed[pvc.UID]; exists {
+				r.Collector.ReleaseRetries.With(pvcLabels(pvc)).Inc()
+				retried = append(retried, pvc)
+			}
 		}
+		pvcListPendingDeletion = retried
+	}
+
+	requeueAfter, err := r.CleanPVCS(ctx, pvcListPendingDeletion, termination)
+	if classifyErr != nil {
+		err = utilerrors.NewAggregate([]error{classifyErr, err})
+	}
+	if err != nil {
+		r.Logger.Error(err, "failed to delete pvc objects from kubernetes")
 
-		if isLocal {
-			r.Logger.Info(fmt.Sprintf("pvc - %s is bounded to a pv with local storage on the terminated node and will be marked for deletion", nodePvc.Name))
-			pvcListPendingDeletion = append(pvcListPendingDeletion, nodePvc)
+		// Held PVCs must be attempted again along with the failed ones, so the retry is narrowed only when none was held.
+		// A failure that is not tied to single PVCs (e.g. listing the release policies) retries all of them.
+		if failed, perPVC := failedPVCs(err); requeueAfter == 0 && perPVC {
+			r.tracker.SetFailedPVCs(termination, failed)
+		} else if requeueAfter == 0 {
+			r.tracker.ClearFailedPVCs(termination)
 		}
+		return ctrl.Result{}, err
 	}
 
-	if err := r.CleanPVCS(ctx, pvcListPendingDeletion); err != nil {
-		r.Logger.Error(err, "failed to delete pvc objects from kubernetes")
+	if requeueAfter > 0 {
+		return ctrl.Result{RequeueAfter: requeueAfter}, nil
+	}
+
+	// A failed VolumeAttachments cleanup is retried here, as the released PVCs are no longer found by the following attempts
+	if err := r.retryPendingCleanups(ctx, termination); err != nil {
+		return ctrl.Result{}, err
 	}
 
+	r.markReleased(ctx, termination)
+
 	return ctrl.Result{}, nil
 }
 
-func (r *PVCReconciler) CleanPVCS(ctx context.Context, pvcs []*v1.PersistentVolumeClaim) error {
+// markReleased records the termination as handled, persisting it across restarts and replicas when enabled
+func (r *PVCReconciler) markReleased(ctx context.Context, termination nodeTermination) {
+	r.tracker.MarkReleased(termination)
+
+	if r.ProcessedEvents == nil {
+		return
+	}
+	if err := r.ProcessedEvents.MarkProcessed(ctx, termination); err != nil {
+		r.Logger.Error(err, fmt.Sprintf("failed to persist the processed termination of node - %s", termination.NodeName))
+	}
+}
+
+// resolveTermination translates a reconcile request into the node termination it represents.
+// Events are namespaced objects, while requests enqueued by the node watch carry only the cluster-scoped node name.
+func (r *PVCReconciler) resolveTermination(ctx context.Context, req ctrl.Request) (nodeTermination, bool, error) {
+	if req.Namespace == "" {
+		termination, exists := r.tracker.DeletedNode(req.Name)
+		if !exists {
+			r.Logger.Info(fmt.Sprintf("node deletion of - %s is no longer tracked. will not take any action", req.Name))
+			return nodeTermination{}, false, nil
+		}
+
+		r.Logger.Info("node deletion found", "Node", termination.NodeName, "NodeID", termination.NodeUID)
+		return termination, true, nil
+	}
+
+	nodeTerminationEvent := &v1.Event{}
+	if err := r.Get(ctx, req.NamespacedName, nodeTerminationEvent); err != nil {
+		r.Logger.Error(err, "did not find the related NodeTermination event")
+
+		return nodeTermination{}, false, err
+	}
+
+	r.Logger.Info("node termination event found", "Message", nodeTerminationEvent.Message, "EventID", nodeTerminationEvent.UID, "EventTime", nodeTerminationEvent.LastTimestamp)
+
+	nodeName, nodeUID, matched := r.Triggers.Match(nodeTerminationEvent)
+	if !matched {
+		r.Logger.Info(fmt.Sprintf("event - %s does not match any trigger rule. will not take any action", nodeTerminationEvent.Name))
+		return nodeTermination{}, false, nil
+	}
+
+	return nodeTermination{
+		NodeName: nodeName,
+		NodeUID:  nodeUID,
+		Source:   TerminationSourceEvent,
+		Time:     eventTime(nodeTerminationEvent),
+		EventUID: nodeTerminationEvent.UID,
+	}, true, nil
+}
+
+// nodeReturned checks whether the terminated node came back during the release grace period.
+// A Node object carrying a different UID than the terminated one is not considered as the same node.
+func (r *PVCReconciler) nodeReturned(ctx context.Context, termination nodeTermination) (bool, error) {
+	node := &v1.Node{}
+	if err := r.Get(ctx, client.ObjectKey{Name: termination.NodeName}, node); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if termination.NodeUID != "" && node.UID != termination.NodeUID {
+		r.Logger.Info(fmt.Sprintf("node - %s was re-created with a different uid, the terminated node is gone", termination.NodeName), "NodeID", termination.NodeUID, "NewNodeID", node.UID)
+		return false, nil
+	}
+
+	r.Logger.Info(fmt.Sprintf("node - %s came back within the release grace period. pvc release is cancelled", termination.NodeName), "NodeID", node.UID)
+	r.Recorder.Eventf(node, "Normal", "PVC-Release-Cancelled", "The node %s came back within the release grace period of %s, PersistentVolumeClaims release was cancelled", node.Name, r.ReleaseDelay)
+	r.Collector.CancelledRelease.Inc()
+
+	return true, nil
+}
+
+// eventTime returns the last time the event was observed, supporting both the core and the events.k8s.io fields
+func eventTime(e *v1.Event) time.Time {
+	if !e.LastTimestamp.IsZero() {
+		return e.LastTimestamp.Time
+	}
+	if !e.EventTime.IsZero() {
+		return e.EventTime.Time
+	}
+
+	return e.CreationTimestamp.Time
+}
+
+// CleanPVCS releases the given PVCs according to the ReleasePolicies, or to the PVC annotation selector when no policy exists.
+// PVCs covered by a policy whose delay since the node termination did not elapse yet are kept, and the time left
+// until the earliest of them is due is returned. The policies counters and limits are kept per termination across calls.
+// Unbound PVCs of the reschedule action go through the same selector, policies and dry-run, and are rescheduled instead.
+// Every eligible PVC is attempted, and the failed releases are returned as an aggregate of per-PVC errors.
+func (r *PVCReconciler) CleanPVCS(ctx context.Context, pvcs []*v1.PersistentVolumeClaim, termination nodeTermination) (time.Duration, error) {
+	policies, err := r.listReleasePolicies(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to list release policies")
+	}
+
+	var requeueAfter time.Duration
+	var errs []error
+	var breakerChecked bool
+	outcomes := make(map[string]*policyOutcome)
+	defer r.updateReleasePolicyStatuses(ctx, outcomes)
+
 	for _, pvc := range pvcs {
+		var outcome *policyOutcome
+		dryrun := r.DryRun
+
+		if len(policies) == 0 {
+			if r.PvcSelector && pvc.Annotations[r.PvcAnoCustomKey] != r.PvcAnoCustomValue {
+				r.Logger.Info(fmt.Sprintf("pvc - %s does not match the filtered key:value annotation of - %s:%s and will be skipped", pvc.Name, r.PvcAnoCustomKey, r.PvcAnoCustomValue))
+				r.recordSkipped(pvc, exporters.SkipReasonSelectorMismatch)
+				continue
+			}
+		} else {
+			policy, err := r.matchReleasePolicy(ctx, policies, pvc)
+			if err != nil {
+				errs = append(errs, &pvcReleaseError{PVC: pvc, err: errors.Wrap(err, fmt.Sprintf("failed to evaluate release policies for object - %s,", pvc.GetName()))})
+				continue
+			}
+			if policy == nil {
+				r.Logger.Info(fmt.Sprintf("pvc - %s is not covered by any release policy and will be skipped", pvc.Name))
+				r.recordSkipped(pvc, exporters.SkipReasonPolicyBlocked)
+				continue
+			}
+
+			if policy.Spec.Delay != nil {
+				if remaining := time.Until(termination.Time.Add(policy.Spec.Delay.Duration)); remaining > 0 {
+					r.Logger.Info(fmt.Sprintf("pvc - %s release is delayed by release policy - %s", pvc.Name, policy.Name), "RequeueAfter", remaining)
+					if requeueAfter == 0 || remaining < requeueAfter {
+						requeueAfter = remaining
+					}
+					continue
+				}
+			}
+
+			outcome = outcomes[policy.Name]
+			if outcome == nil {
+				outcome = &policyOutcome{policy: policy}
+				outcomes[policy.Name] = outcome
+			}
+			if r.tracker.MatchPolicy(termination, policy.Name, pvc.UID) {
+				outcome.matched++
+			}
+
+			// Rescheduling keeps the PVC, so it does not count against the releases limit
+			if !r.reschedules(pvc) && !r.tracker.AdmitPolicyRelease(termination, policy.Name, pvc.UID, policy.Spec.MaxReleasesPerNode) {
+				r.Logger.Info(fmt.Sprintf("pvc - %s will be skipped as release policy - %s reached its limit of %d releases for node - %s", pvc.Name, policy.Name, *policy.Spec.MaxReleasesPerNode, termination.NodeName))
+				r.recordSkipped(pvc, exporters.SkipReasonPolicyBlocked)
+				continue
+			}
+
+			if policy.Spec.DryRun {
+				dryrun = true
+			}
+		}
+
+		// A policy dry-run is applied as a server-side dry-run delete, unless the whole controller runs in dry-run mode
+		policyDryRun := dryrun && !r.DryRun
+
+		if r.reschedules(pvc) {
+			if err := r.reschedulePVC(ctx, pvc, dryrun); err != nil {
+				errs = append(errs, &pvcReleaseError{PVC: pvc, err: err})
+			}
+			continue
+		}
 
-		if r.PvcSelector && pvc.Annotations[r.PvcAnoCustomKey] != r.PvcAnoCustomValue {
-			r.Logger.Info(fmt.Sprintf("pvc - %s does not match the filtered key:value annotation of - %s:%s and will be skipped", pvc.Name, r.PvcAnoCustomKey, r.PvcAnoCustomValue))
+		var sts *appsv1.StatefulSet
+		var podName string
+		if r.stsGate != nil && !policyDryRun {
+			sts, podName, err = r.resolveStatefulSet(ctx, pvc)
+			if err != nil {
+				errs = append(errs, &pvcReleaseError{PVC: pvc, err: errors.Wrap(err, fmt.Sprintf("failed to resolve the statefulset of object - %s,", pvc.GetName()))})
+				continue
+			}
+			if sts != nil {
+				admitted, err := r.stsGate.Admit(ctx, r.Client, sts)
+				if err != nil {
+					errs = append(errs, &pvcReleaseError{PVC: pvc, err: errors.Wrap(err, fmt.Sprintf("failed to check the recovering replicas of statefulset - %s,", sts.Name))})
+					continue
+				}
+				if !admitted {
+					r.Logger.Info(fmt.Sprintf("pvc - %s release is held until the recovering replicas of statefulset - %s are ready", pvc.Name, sts.Name), "RequeueAfter", statefulSetRecheckInterval)
+					if requeueAfter == 0 || statefulSetRecheckInterval < requeueAfter {
+						requeueAfter = statefulSetRecheckInterval
+					}
+					continue
+				}
+			}
+		}
+
+		// Dry-run releases are not counted by the circuit breaker, whose state is read once for the whole batch
+		if r.Breaker != nil && !dryrun {
+			if !breakerChecked {
+				open, err := r.Breaker.IsOpen(ctx)
+				if err != nil {
+					return requeueAfter, utilerrors.NewAggregate(append(errs, errors.Wrap(err, "failed to check the circuit breaker")))
+				}
+				if open {
+					r.Logger.Info(fmt.Sprintf("circuit breaker is open, pvc - %s and the rest of the pvc objects are held", pvc.Name))
+					return circuitBreakerRecheckInterval, utilerrors.NewAggregate(errs)
+				}
+				breakerChecked = true
+			}
+
+			admitted, err := r.Breaker.AdmitPVC(ctx, pvc)
+			if err != nil {
+				errs = append(errs, &pvcReleaseError{PVC: pvc, err: errors.Wrap(err, "failed to check the circuit breaker")})
+				continue
+			}
+			if !admitted {
+				r.Logger.Info(fmt.Sprintf("circuit breaker is open, pvc - %s and the rest of the pvc objects are held", pvc.Name))
+				return circuitBreakerRecheckInterval, utilerrors.NewAggregate(errs)
+			}
+		}
+
+		if owner := ephemeralVolumeOwner(pvc); owner != nil {
+			released, err := r.releaseEphemeralVolume(ctx, pvc, owner, dryrun)
+			if err != nil {
+				r.recordFailed(pvc)
+				errs = append(errs, &pvcReleaseError{PVC: pvc, err: err})
+				continue
+			}
+			if !released {
+				continue
+			}
+
+			r.completeRelease(ctx, termination, pvcRelease{PVC: pvc, Outcome: outcome, StatefulSet: sts, PodName: podName, DryRun: dryrun})
 			continue
 		}
 
-		err := r.Delete(ctx, pvc)
+		// A standalone PVC is snapshotted before the delete, as nothing else would re-create it
+		var reprovision *reprovisionedPVC
+		if r.Reprovisioner != nil {
+			standalone, err := r.isStandalonePVC(ctx, pvc)
+			if err != nil {
+				errs = append(errs, &pvcReleaseError{PVC: pvc, err: err})
+				continue
+			}
+			if standalone {
+				reprovision, err = r.Reprovisioner.Snapshot(ctx, pvc, dryrun)
+				if err != nil {
+					r.recordFailed(pvc)
+					errs = append(errs, &pvcReleaseError{PVC: pvc, err: err})
+					continue
+				}
+			}
+		}
+
+		// The preconditions protect a PVC re-created with the same name, or changed since it was evaluated, from a blind delete
+		deleteOpts := []client.DeleteOption{client.Preconditions{UID: &pvc.UID, ResourceVersion: &pvc.ResourceVersion}}
+		if policyDryRun {
+			deleteOpts = append(deleteOpts, client.DryRunAll)
+		}
+
+		err := r.Delete(ctx, pvc, deleteOpts...)
+		if err != nil && reprovision != nil {
+			r.Reprovisioner.Discard(ctx, reprovision)
+		}
+		if apierrors.IsConflict(err) {
+			r.Collector.PreconditionConflicts.With(pvcLabels(pvc)).Inc()
+			if err := r.reevaluateConflictedPVC(ctx, pvc); err != nil {
+				errs = append(errs, &pvcReleaseError{PVC: pvc, err: err})
+			}
+			continue
+		}
 		if err != nil {
-			return errors.Wrap(err, fmt.Sprintf("failed to delete object - %s,", pvc.GetName()))
+			r.recordFailed(pvc)
+			errs = append(errs, &pvcReleaseError{PVC: pvc, err: errors.Wrap(err, fmt.Sprintf("failed to delete object - %s,", pvc.GetName()))})
+			continue
+		}
+
+		if policyDryRun {
+			r.Recorder.Eventf(pvc, "Normal", "PVC-Release-DryRun", "The PersistentVolumeClaim %s would have been released by release policy %s", pvc.Name, outcome.policy.Name)
+		} else {
+			r.Recorder.Eventf(pvc, "Normal", "PVC-Released", "The PersistentVolumeClaim %s has been released", pvc.Name)
+		}
+		r.Collector.DeletedPVC.With(prometheus.Labels{"dryrun": strconv.FormatBool(dryrun)}).Inc()
+
+		r.Logger.Info(fmt.Sprintf("pvc object - %s was deleted successfully", pvc.GetName()), "dryrun", dryrun)
+
+		r.completeRelease(ctx, termination, pvcRelease{PVC: pvc, Outcome: outcome, StatefulSet: sts, PodName: podName, DryRun: dryrun, Reprovision: reprovision})
+	}
+
+	return requeueAfter, utilerrors.NewAggregate(errs)
+}
+
+// pvcRelease is a PVC released either by its deletion or by the deletion of its owning pod
+type pvcRelease struct {
+	PVC         *v1.PersistentVolumeClaim
+	Outcome     *policyOutcome
+	StatefulSet *appsv1.StatefulSet
+	PodName     string
+	DryRun      bool
+	Reprovision *reprovisionedPVC
+}
+
+// completeRelease does the bookkeeping and the follow-up cleanups shared by every release path.
+// A failed cleanup does not fail the release, as the PVC is already gone.
+func (r *PVCReconciler) completeRelease(ctx context.Context, termination nodeTermination, release pvcRelease) {
+	pvc, dryrun := release.PVC, release.DryRun
+
+	if release.Outcome != nil && !dryrun {
+		release.Outcome.released++
+	}
+	if r.Breaker != nil && !dryrun {
+		r.Breaker.RecordRelease(pvc)
+	}
+	if release.StatefulSet != nil && !dryrun {
+		r.stsGate.Record(release.StatefulSet, pvc, release.PodName)
+	}
+	if r.Recovery != nil && !dryrun {
+		r.Recovery.Track(pvc, termination.Time)
+	}
+	if r.PVJanitor != nil {
+		r.PVJanitor.Track(pvc, dryrun)
+	}
+	if release.Reprovision != nil {
+		r.Reprovisioner.Track(release.Reprovision)
+	}
+	r.recordReleased(pvc, dryrun, termination.Time)
+
+	// The owning pod of an ephemeral volume was already deleted by its release
+	if r.ForceDeletePods && !(dryrun && !r.DryRun) && ephemeralVolumeOwner(pvc) == nil {
+		if err := r.forceDeleteStuckPods(ctx, pvc); err != nil {
+			r.Logger.Error(err, fmt.Sprintf("failed to force delete the pods referencing pvc - %s", pvc.GetName()))
 		}
+	}
+	if r.CleanVolumeAttachments {
+		if err := r.cleanVolumeAttachments(ctx, pvc, dryrun); err != nil {
+			r.Logger.Error(err, fmt.Sprintf("failed to clean the volume attachments of pvc - %s", pvc.GetName()))
+			r.tracker.AddPendingCleanup(termination, pvc, dryrun)
+		}
+	}
+}
 
-		r.Recorder.Eventf(pvc, "Normal", "PVC-Released", "The PersistentVolumeClaim %s has been released", pvc.Name)
-		r.Collector.DeletedPVC.With(prometheus.Labels{"dryrun": strconv.FormatBool(r.DryRun)}).Inc()
+// reevaluateConflictedPVC re-reads a PVC whose delete preconditions failed. A PVC re-created with the same name is a
+// different claim and is left alone, while a changed PVC is returned as a failure so the retry re-evaluates it.
+func (r *PVCReconciler) reevaluateConflictedPVC(ctx context.Context, pvc *v1.PersistentVolumeClaim) error {
+	current := &v1.PersistentVolumeClaim{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(pvc), current); err != nil {
+		if apierrors.IsNotFound(err) {
+			r.Logger.Info(fmt.Sprintf("pvc - %s delete preconditions failed as it is already gone", pvc.Name), "Namespace", pvc.Namespace)
+			return nil
+		}
+		return errors.Wrap(err, fmt.Sprintf("failed to re-read object - %s,", pvc.GetName()))
+	}
 
-		r.Logger.Info(fmt.Sprintf("pvc object - %s was deleted successfully", pvc.GetName()))
+	if current.UID != pvc.UID {
+		r.Logger.Info(fmt.Sprintf("pvc - %s was re-created since it was evaluated and will not be deleted", pvc.Name), "Namespace", pvc.Namespace, "EvaluatedUID", pvc.UID, "CurrentUID", current.UID)
+		return nil
 	}
 
-	return nil
+	r.Logger.Info(fmt.Sprintf("pvc - %s changed since it was evaluated and will be re-evaluated", pvc.Name), "Namespace", pvc.Namespace, "EvaluatedResourceVersion", pvc.ResourceVersion, "CurrentResourceVersion", current.ResourceVersion)
+	return errors.Errorf("object - %s changed since it was evaluated", pvc.GetName())
+}
+
+// pvcReleaseError is the failure to release a single PVC, collected into the aggregate error of CleanPVCS
+type pvcReleaseError struct {
+	PVC *v1.PersistentVolumeClaim
+	err error
+}
+
+func (e *pvcReleaseError) Error() string {
+	return e.err.Error()
+}
+
+func (e *pvcReleaseError) Unwrap() error {
+	return e.err
+}
+
+// failedPVCs returns the UIDs of the PVCs whose classification or release failed according to the aggregate error.
+// It reports false unless the error is made of per-PVC failures only, so the retry cannot skip PVCs never attempted.
+func failedPVCs(err error) (map[types.UID]struct{}, bool) {
+	var aggregate utilerrors.Aggregate
+	if !stderrors.As(err, &aggregate) {
+		return nil, false
+	}
+
+	failed := make(map[types.UID]struct{})
+	for _, e := range utilerrors.Flatten(aggregate).Errors() {
+		var releaseErr *pvcReleaseError
+		if !stderrors.As(e, &releaseErr) {
+			return nil, false
+		}
+		failed[releaseErr.PVC.UID] = struct{}{}
+	}
+
+	return failed, len(failed) > 0
 }
 
 func (r *PVCReconciler) FilterPVCListByNodeName(pvcList *v1.PersistentVolumeClaimList, nodeName string) []*v1.PersistentVolumeClaim {
@@ -142,37 +655,178 @@ func (r *PVCReconciler) FilterPVCListByNodeName(pvcList *v1.PersistentVolumeClai
 	return relatedPVCs
 }
 
+// FilterPVCListByPVNodeAffinity returns the PVCs bound to the PVs whose required node affinity pins them to the given node.
+// It covers statically provisioned PVs, where the PVC is not annotated with the selected node.
+func (r *PVCReconciler) FilterPVCListByPVNodeAffinity(ctx context.Context, pvList *v1.PersistentVolumeList, nodeName string) ([]*v1.PersistentVolumeClaim, error) {
+	var relatedPVCs []*v1.PersistentVolumeClaim
+
+	for i := 0; i < len(pvList.Items); i++ {
+		pv := &pvList.Items[i]
+
+		pvNode, pinned := classifier.PinnedNode(pv, r.NodeTopologyKey)
+		if !pinned || pvNode != nodeName || pv.Spec.ClaimRef == nil {
+			continue
+		}
+
+		pvc := &v1.PersistentVolumeClaim{}
+		pvcKey := client.ObjectKey{Namespace: pv.Spec.ClaimRef.Namespace, Name: pv.Spec.ClaimRef.Name}
+		if err := r.Get(ctx, pvcKey, pvc); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		// The claim reference may point to a PVC that was re-created under the same name
+		if pv.Spec.ClaimRef.UID != "" && pv.Spec.ClaimRef.UID != pvc.UID {
+			continue
+		}
+
+		r.Logger.Info(fmt.Sprintf("pvc - %s is bounded to pv - %s pinned to node - %s. will be marked for pv 'local' plugin scan.", pvc.Name, pv.Name, nodeName))
+		relatedPVCs = append(relatedPVCs, pvc)
+	}
+
+	return relatedPVCs, nil
+}
+
+// mergePVCLists appends the PVCs of the second list that are not already in the first one
+func mergePVCLists(pvcs []*v1.PersistentVolumeClaim, others []*v1.PersistentVolumeClaim) []*v1.PersistentVolumeClaim {
+	known := make(map[types.NamespacedName]struct{}, len(pvcs))
+	for _, pvc := range pvcs {
+		known[client.ObjectKeyFromObject(pvc)] = struct{}{}
+	}
+
+	for _, pvc := range others {
+		if _, exists := known[client.ObjectKeyFromObject(pvc)]; !exists {
+			pvcs = append(pvcs, pvc)
+		}
+	}
+
+	return pvcs
+}
+
 func (r *PVCReconciler) CheckLocalPvStoragePluginByPVC(ctx context.Context, pvc *v1.PersistentVolumeClaim) (error, bool) {
+	pv, err := r.getBoundPV(ctx, pvc)
+	if err != nil {
+		return err, false
+	}
+
+	return nil, r.Classifier.IsNodeLocal(pv)
+}
+
+// getBoundPV returns the PV the PVC is bound to. A PV that is already gone is a normal state handled by the callers,
+// so only the other failures are logged as errors.
+func (r *PVCReconciler) getBoundPV(ctx context.Context, pvc *v1.PersistentVolumeClaim) (*v1.PersistentVolume, error) {
 	pv := &v1.PersistentVolume{}
-	pvKey := client.ObjectKey{Name: pvc.Spec.VolumeName}
+	if err := r.Get(ctx, client.ObjectKey{Name: pvc.Spec.VolumeName}, pv); err != nil {
+		if !apierrors.IsNotFound(err) {
+			r.Logger.Error(err, fmt.Sprintf("could not find the attached pv object - %s", pvc.Spec.VolumeName))
+		}
+		return nil, err
+	}
 
-	if err := r.Get(ctx, pvKey, pv); err != nil {
-		r.Logger.Error(err, fmt.Sprintf("could not find the attached pv object - %s", pvc.Spec.VolumeName))
-		return err, false
+	return pv, nil
+}
+
+// LocatePVC returns the node holding the storage of the PVC, and whether that storage is node-local.
+// The node is taken from the selected-node annotation, or from the PV node affinity for statically provisioned PVs.
+func (r *PVCReconciler) LocatePVC(ctx context.Context, pvc *v1.PersistentVolumeClaim) (string, bool, error) {
+	pv, err := r.getBoundPV(ctx, pvc)
+	if err != nil {
+		return "", false, client.IgnoreNotFound(err)
+	}
+	if !r.Classifier.IsNodeLocal(pv) {
+		return "", false, nil
 	}
 
-	if pv.Spec.Local != nil {
-		return nil, true
+	if nodeName := pvc.Annotations[PVCnodeAnnotationKey]; nodeName != "" {
+		return nodeName, true, nil
 	}
 
-	return nil, false
+	nodeName, _ := classifier.PinnedNode(pv, r.NodeTopologyKey)
+	return nodeName, true, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *PVCReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.tracker = newTerminationTracker(r.ReleaseDelay)
+	if r.ProcessedEvents != nil && r.ProcessedEvents.Retention == 0 {
+		r.ProcessedEvents.Retention = max(terminationRetention, r.MaxEventAge) + r.ReleaseDelay
+	}
+	if r.MaxConcurrentStatefulSetReleases > 0 {
+		r.stsGate = newStatefulSetGate(r.MaxConcurrentStatefulSetReleases, r.StatefulSetRecoveryTimeout)
+	}
+
+	if r.NodeTopologyKey == "" {
+		r.NodeTopologyKey = classifier.HostnameTopologyKey
+	}
+
+	switch r.UnboundPVCAction {
+	case "":
+		r.UnboundPVCAction = UnboundPVCActionReschedule
+	case UnboundPVCActionReschedule, UnboundPVCActionDelete, UnboundPVCActionSkip:
+	default:
+		return errors.Errorf("invalid unbound pvc action - %s", r.UnboundPVCAction)
+	}
+
+	if r.Classifier == nil {
+		r.Classifier = classifier.New(nil, nil)
+	}
+
+	if err := r.setupIndexers(context.Background(), mgr); err != nil {
+		return err
+	}
+
+	if r.Triggers == nil {
+		rules, err := triggers.NewRuleSet(triggers.DefaultRules())
+		if err != nil {
+			return err
+		}
+		r.Triggers = rules
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&v1.Event{}).WithEventFilter(onNodeTerminationEventCreatedPredicate()).
+		For(&v1.Event{}, builder.WithPredicates(r.onNodeTerminationEventCreatedPredicate())).
+		Watches(&v1.Node{}, r.nodeDeletionHandler()).
 		Complete(r)
 }
 
-func onNodeTerminationEventCreatedPredicate() predicate.Predicate {
+// onNodeTerminationEventCreatedPredicate admits the created events matching the trigger rules.
+// The max event age is checked only here, when the termination is first seen, so the requeues of an admitted
+// termination (grace period, policy delays, held releases and retries) are never dropped once the event gets old.
+func (r *PVCReconciler) onNodeTerminationEventCreatedPredicate() predicate.Predicate {
 	return predicate.Funcs{
 		CreateFunc: func(e event.CreateEvent) bool {
 			obj := e.Object.(*v1.Event)
-			return obj.Reason == RemovingNode && obj.Source.Component == NodeControllerComponent
+			if _, _, matched := r.Triggers.Match(obj); !matched {
+				return false
+			}
+
+			if age := time.Since(eventTime(obj)); r.MaxEventAge > 0 && age > r.MaxEventAge {
+				r.Logger.Info(fmt.Sprintf("event - %s is older than the max event age of %s and will be ignored", obj.Name, r.MaxEventAge), "Age", age)
+				return false
+			}
+
+			return true
 		},
 		DeleteFunc:  func(e event.DeleteEvent) bool { return false },
 		UpdateFunc:  func(e event.UpdateEvent) bool { return false },
 		GenericFunc: func(e event.GenericEvent) bool { return false },
 	}
 }
+
+// nodeDeletionHandler enqueues the name of every deleted Node, so the release flow starts
+// even when the RemovingNode event was lost or fired while the controller was down.
+func (r *PVCReconciler) nodeDeletionHandler() handler.EventHandler {
+	return handler.Funcs{
+		DeleteFunc: func(ctx context.Context, e event.DeleteEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			r.tracker.ObserveNodeDeletion(nodeTermination{
+				NodeName: e.Object.GetName(),
+				NodeUID:  e.Object.GetUID(),
+				Source:   TerminationSourceNodeDeletion,
+				Time:     time.Now(),
+			})
+			q.Add(reconcile.Request{NamespacedName: types.NamespacedName{Name: e.Object.GetName()}})
+		},
+	}
+}
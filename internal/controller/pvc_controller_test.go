@@ -0,0 +1,285 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	releaserv1alpha1 "github.com/AppsFlyer/local-pvc-releaser/api/v1alpha1"
+	"github.com/AppsFlyer/local-pvc-releaser/internal/classifier"
+	"github.com/AppsFlyer/local-pvc-releaser/internal/exporters"
+	"github.com/AppsFlyer/local-pvc-releaser/internal/triggers"
+)
+
+const testNode = "node-1"
+
+// newTestReconciler returns a reconciler backed by a fake client indexed the same way the manager cache is
+func newTestReconciler(t *testing.T, funcs interceptor.Funcs, objs ...client.Object) *PVCReconciler {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, releaserv1alpha1.AddToScheme(scheme))
+
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithStatusSubresource(&releaserv1alpha1.ReleasePolicy{}).
+		WithIndex(&v1.PersistentVolumeClaim{}, PVCSelectedNodeIndex, pvcSelectedNodeIndexer).
+		WithIndex(&v1.PersistentVolume{}, PVNodeAffinityIndex, pvNodeAffinityIndexer(classifier.HostnameTopologyKey)).
+		WithIndex(&storagev1.VolumeAttachment{}, VolumeAttachmentPVIndex, volumeAttachmentPVIndexer).
+		WithInterceptorFuncs(funcs).
+		Build()
+
+	logger := logr.Discard()
+
+	return &PVCReconciler{
+		Client:           c,
+		Scheme:           scheme,
+		Logger:           &logger,
+		Recorder:         record.NewFakeRecorder(100),
+		Collector:        exporters.NewCollector(),
+		Classifier:       classifier.New(nil, nil),
+		NodeTopologyKey:  classifier.HostnameTopologyKey,
+		MissingPVPolicy:  MissingPVPolicySkip,
+		UnboundPVCAction: UnboundPVCActionSkip,
+		tracker:          newTerminationTracker(0),
+	}
+}
+
+func testPVC(name, volumeName string) *v1.PersistentVolumeClaim {
+	storageClass := "local-storage"
+
+	return &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   "default",
+			UID:         types.UID(name),
+			Annotations: map[string]string{PVCnodeAnnotationKey: testNode},
+		},
+		Spec: v1.PersistentVolumeClaimSpec{
+			StorageClassName: &storageClass,
+			VolumeName:       volumeName,
+		},
+	}
+}
+
+func testLocalPV(name string) *v1.PersistentVolume {
+	return &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: v1.PersistentVolumeSpec{
+			PersistentVolumeSource: v1.PersistentVolumeSource{Local: &v1.LocalVolumeSource{Path: "/mnt/disks/" + name}},
+		},
+	}
+}
+
+// deleteNode observes the deletion of the test node and returns the request the node watch enqueues for it
+func deleteNode(r *PVCReconciler) ctrl.Request {
+	r.tracker.ObserveNodeDeletion(nodeTermination{
+		NodeName: testNode,
+		NodeUID:  "node-uid",
+		Source:   TerminationSourceNodeDeletion,
+		Time:     time.Now(),
+	})
+
+	return ctrl.Request{NamespacedName: types.NamespacedName{Name: testNode}}
+}
+
+func pvcExists(t *testing.T, r *PVCReconciler, name string) bool {
+	t.Helper()
+
+	err := r.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: name}, &v1.PersistentVolumeClaim{})
+	if apierrors.IsNotFound(err) {
+		return false
+	}
+	require.NoError(t, err)
+
+	return true
+}
+
+func TestReconcileRetriesAllPVCsAfterReleasePolicyListFailure(t *testing.T) {
+	failPolicyList := true
+	funcs := interceptor.Funcs{
+		List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+			if _, ok := list.(*releaserv1alpha1.ReleasePolicyList); ok && failPolicyList {
+				return errors.New("release policies are unavailable")
+			}
+			return c.List(ctx, list, opts...)
+		},
+	}
+	r := newTestReconciler(t, funcs,
+		testPVC("data-0", "pv-0"), testLocalPV("pv-0"),
+		testPVC("data-1", "pv-1"), testLocalPV("pv-1"),
+	)
+	req := deleteNode(r)
+
+	_, err := r.Reconcile(context.Background(), req)
+	require.Error(t, err)
+	assert.Nil(t, r.tracker.FailedPVCs(nodeTermination{NodeName: testNode}))
+
+	failPolicyList = false
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+
+	assert.False(t, pvcExists(t, r, "data-0"))
+	assert.False(t, pvcExists(t, r, "data-1"))
+	assert.True(t, r.tracker.IsReleased(nodeTermination{NodeName: testNode}))
+}
+
+func TestReconcileRetriesOnlyFailedPVCs(t *testing.T) {
+	failDelete := true
+	funcs := interceptor.Funcs{
+		Delete: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+			if obj.GetName() == "data-1" && failDelete {
+				return errors.New("delete failed")
+			}
+			return c.Delete(ctx, obj, opts...)
+		},
+	}
+	r := newTestReconciler(t, funcs,
+		testPVC("data-0", "pv-0"), testLocalPV("pv-0"),
+		testPVC("data-1", "pv-1"), testLocalPV("pv-1"),
+	)
+	req := deleteNode(r)
+
+	_, err := r.Reconcile(context.Background(), req)
+	require.Error(t, err)
+	assert.False(t, pvcExists(t, r, "data-0"))
+	assert.Equal(t, map[types.UID]struct{}{"data-1": {}}, r.tracker.FailedPVCs(nodeTermination{NodeName: testNode}))
+
+	failDelete = false
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	assert.False(t, pvcExists(t, r, "data-1"))
+}
+
+func TestFailedPVCs(t *testing.T) {
+	pvc := testPVC("data-0", "pv-0")
+
+	failed, perPVC := failedPVCs(errors.New("release policies are unavailable"))
+	assert.False(t, perPVC)
+	assert.Nil(t, failed)
+
+	mixed := utilerrors.NewAggregate([]error{&pvcReleaseError{PVC: pvc, err: errors.New("classify failed")}, errors.New("release policies are unavailable")})
+	_, perPVC = failedPVCs(mixed)
+	assert.False(t, perPVC)
+
+	nested := utilerrors.NewAggregate([]error{utilerrors.NewAggregate([]error{&pvcReleaseError{PVC: pvc, err: errors.New("delete failed")}})})
+	failed, perPVC = failedPVCs(nested)
+	assert.True(t, perPVC)
+	assert.Equal(t, map[types.UID]struct{}{"data-0": {}}, failed)
+}
+
+func TestLocatePVC(t *testing.T) {
+	pvGets := 0
+	funcs := interceptor.Funcs{
+		Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			if _, ok := obj.(*v1.PersistentVolume); ok {
+				pvGets++
+			}
+			return c.Get(ctx, key, obj, opts...)
+		},
+	}
+	static := testPVC("static", "pv-static")
+	delete(static.Annotations, PVCnodeAnnotationKey)
+	staticPV := testLocalPV("pv-static")
+	staticPV.Spec.NodeAffinity = &v1.VolumeNodeAffinity{Required: &v1.NodeSelector{NodeSelectorTerms: []v1.NodeSelectorTerm{{
+		MatchExpressions: []v1.NodeSelectorRequirement{{Key: classifier.HostnameTopologyKey, Operator: v1.NodeSelectorOpIn, Values: []string{"node-2"}}},
+	}}}}
+	r := newTestReconciler(t, funcs, testLocalPV("pv-0"), staticPV)
+
+	nodeName, isLocal, err := r.LocatePVC(context.Background(), testPVC("data-0", "pv-0"))
+	require.NoError(t, err)
+	assert.True(t, isLocal)
+	assert.Equal(t, testNode, nodeName)
+	assert.Equal(t, 1, pvGets)
+
+	nodeName, isLocal, err = r.LocatePVC(context.Background(), static)
+	require.NoError(t, err)
+	assert.True(t, isLocal)
+	assert.Equal(t, "node-2", nodeName)
+	assert.Equal(t, 2, pvGets)
+
+	// A PV that is already gone is not an error
+	_, isLocal, err = r.LocatePVC(context.Background(), testPVC("data-1", "pv-missing"))
+	require.NoError(t, err)
+	assert.False(t, isLocal)
+}
+
+func testTerminationEvent(name string, at time.Time) *v1.Event {
+	return &v1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(name)},
+		Reason:         triggers.RemovingNodeReason,
+		Source:         v1.EventSource{Component: triggers.NodeControllerComponent},
+		InvolvedObject: v1.ObjectReference{Kind: triggers.NodeKind, Name: testNode, UID: "node-uid"},
+		LastTimestamp:  metav1.NewTime(at),
+	}
+}
+
+func TestMaxEventAgeAppliesOnlyToNewTerminations(t *testing.T) {
+	old := testTerminationEvent("old", time.Now().Add(-2*time.Hour))
+	recent := testTerminationEvent("recent", time.Now().Add(-time.Minute))
+	r := newTestReconciler(t, interceptor.Funcs{}, old, recent, testPVC("data-0", "pv-0"), testLocalPV("pv-0"))
+	r.Triggers, _ = triggers.NewRuleSet(nil)
+	r.MaxEventAge = time.Hour
+
+	p := r.onNodeTerminationEventCreatedPredicate()
+	assert.False(t, p.Create(event.CreateEvent{Object: old}))
+	assert.True(t, p.Create(event.CreateEvent{Object: recent}))
+
+	// An admitted termination requeued past the max event age is still released
+	r.ReleaseDelay = 90 * time.Minute
+	recent.LastTimestamp = metav1.NewTime(time.Now().Add(-2 * time.Hour))
+	require.NoError(t, r.Update(context.Background(), recent))
+
+	_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(recent)})
+	require.NoError(t, err)
+	assert.False(t, pvcExists(t, r, "data-0"))
+}
+
+func TestCancelledReleaseIsNotMarkedReleased(t *testing.T) {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: testNode, UID: "node-uid"}}
+	r := newTestReconciler(t, interceptor.Funcs{}, node, testPVC("data-0", "pv-0"), testLocalPV("pv-0"))
+	r.ReleaseDelay = time.Minute
+	termination := nodeTermination{
+		NodeName: testNode,
+		NodeUID:  "node-uid",
+		Source:   TerminationSourceNodeDeletion,
+		Time:     time.Now().Add(-2 * time.Minute),
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: testNode}}
+
+	// The node came back within the grace period
+	r.tracker.ObserveNodeDeletion(termination)
+	_, err := r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	assert.True(t, pvcExists(t, r, "data-0"))
+	assert.False(t, r.tracker.IsReleased(termination))
+
+	// A later deletion of the same node is still handled
+	require.NoError(t, r.Delete(context.Background(), node))
+	r.tracker.ObserveNodeDeletion(termination)
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	assert.False(t, pvcExists(t, r, "data-0"))
+	assert.True(t, r.tracker.IsReleased(termination))
+}
@@ -0,0 +1,322 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/AppsFlyer/local-pvc-releaser/internal/exporters"
+)
+
+const (
+	reprovisionCheckInterval = 10 * time.Second
+
+	// maxSnapshotsSize is the size limit of the ConfigMap data enforced by the API server
+	maxSnapshotsSize = 1024 * 1024
+)
+
+// bindingAnnotations are set by the PV controller, the scheduler and the provisioner while binding the PVC, and must not be
+// carried over to the re-created PVC
+var bindingAnnotations = []string{
+	PVCnodeAnnotationKey,
+	"pv.kubernetes.io/bind-completed",
+	"pv.kubernetes.io/bound-by-controller",
+	"volume.beta.kubernetes.io/storage-provisioner",
+	"volume.kubernetes.io/storage-provisioner",
+}
+
+// reprovisionedPVC is the snapshot of a released standalone PVC, re-created once the released PVC is gone
+type reprovisionedPVC struct {
+	Snapshot   *v1.PersistentVolumeClaim `json:"snapshot"`
+	ReleasedAt time.Time                 `json:"releasedAt"`
+	DryRun     bool                      `json:"-"`
+}
+
+// PVCReprovisioner re-creates the standalone PVCs released by the controller. Unlike the StatefulSet volumeClaimTemplates,
+// nothing re-creates a hand-made PVC mounted by a Deployment or a bare pod, which then stays Pending forever.
+// The PVC is re-created from its snapshot without the volumeName and the binding annotations, so the same pod spec can bind
+// a new volume on a live node.
+// The snapshots are persisted in a ConfigMap, keyed by the PVC UID, before the PVCs are deleted, so a restart or a leader
+// failover between the delete and the re-creation does not lose them.
+type PVCReprovisioner struct {
+	Client    client.Client
+	Reader    client.Reader
+	Namespace string
+	Name      string
+	Recorder  record.EventRecorder
+	Collector *exporters.Collector
+	Logger    *logr.Logger
+
+	leaderOnly
+	pending trackedEntries[types.UID, *reprovisionedPVC]
+}
+
+// Snapshot persists the snapshot of a PVC about to be released, it must succeed before the PVC is deleted.
+// The PVC is read from the API server, as the cached object may be stripped of its metadata.
+func (p *PVCReprovisioner) Snapshot(ctx context.Context, pvc *v1.PersistentVolumeClaim, dryrun bool) (*reprovisionedPVC, error) {
+	// A dry-run release never removes the PVC, so it is only reported and nothing is persisted
+	if dryrun {
+		return &reprovisionedPVC{Snapshot: snapshotObject(pvc), ReleasedAt: time.Now().UTC(), DryRun: true}, nil
+	}
+
+	current := &v1.PersistentVolumeClaim{}
+	if err := p.Reader.Get(ctx, client.ObjectKeyFromObject(pvc), current); err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to snapshot object - %s,", pvc.GetName()))
+	}
+	if current.UID != pvc.UID {
+		return nil, errors.Errorf("failed to snapshot object - %s, it was re-created", pvc.GetName())
+	}
+
+	snapshot := snapshotObject(current)
+	release := &reprovisionedPVC{Snapshot: snapshot, ReleasedAt: time.Now().UTC()}
+	value, err := json.Marshal(release)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to snapshot object - %s,", pvc.GetName()))
+	}
+
+	err = p.updateSnapshots(ctx, func(data map[string]string) {
+		data[string(snapshot.UID)] = string(value)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to persist the snapshot of object - %s,", pvc.GetName()))
+	}
+
+	return release, nil
+}
+
+// Track starts following the snapshotted PVC once released, to re-create it once it is gone
+func (p *PVCReprovisioner) Track(release *reprovisionedPVC) {
+	p.pending.trackSince(release.Snapshot.UID, release, release.ReleasedAt)
+}
+
+// Discard removes the persisted snapshot of a PVC whose release failed
+func (p *PVCReprovisioner) Discard(ctx context.Context, release *reprovisionedPVC) {
+	if release.DryRun {
+		return
+	}
+
+	if err := p.removeSnapshot(ctx, release); err != nil {
+		p.Logger.Error(err, fmt.Sprintf("failed to remove the snapshot of pvc - %s", release.Snapshot.Name), "Namespace", release.Snapshot.Namespace)
+	}
+}
+
+// Start implements manager.Runnable
+func (p *PVCReprovisioner) Start(ctx context.Context) error {
+	if err := p.load(ctx); err != nil {
+		return err
+	}
+
+	return runEvery(ctx, reprovisionCheckInterval, p.check)
+}
+
+// load tracks the snapshots persisted before a restart or a leader failover
+func (p *PVCReprovisioner) load(ctx context.Context) error {
+	configMap := &v1.ConfigMap{}
+	if err := p.Reader.Get(ctx, client.ObjectKey{Namespace: p.Namespace, Name: p.Name}, configMap); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return errors.Wrap(err, "failed to load the pvc snapshots")
+	}
+
+	for key, value := range configMap.Data {
+		release := &reprovisionedPVC{}
+		if err := json.Unmarshal([]byte(value), release); err != nil || release.Snapshot == nil {
+			p.Logger.Info(fmt.Sprintf("invalid pvc snapshot - %s will be ignored", key))
+			continue
+		}
+		p.Track(release)
+	}
+
+	return nil
+}
+
+func (p *PVCReprovisioner) check(ctx context.Context) {
+	p.pending.checkEach(ctx, func(ctx context.Context, release *reprovisionedPVC) bool {
+		done, err := p.reprovision(ctx, release)
+		if err != nil {
+			p.Logger.Error(err, fmt.Sprintf("failed to re-create pvc - %s", release.Snapshot.Name), "Namespace", release.Snapshot.Namespace)
+		}
+		if !done || release.DryRun {
+			return done
+		}
+
+		// The PVC stays tracked until its snapshot is removed, the next check finds it re-created
+		if err := p.removeSnapshot(ctx, release); err != nil {
+			p.Logger.Error(err, fmt.Sprintf("failed to remove the snapshot of pvc - %s", release.Snapshot.Name), "Namespace", release.Snapshot.Namespace)
+			return false
+		}
+		return true
+	}, func(release *reprovisionedPVC) {
+		p.Logger.Info(fmt.Sprintf("pvc - %s was not removed within %s and will not be re-created", release.Snapshot.Name, trackingRetention), "Namespace", release.Snapshot.Namespace)
+		p.Discard(ctx, release)
+	})
+}
+
+func (p *PVCReprovisioner) removeSnapshot(ctx context.Context, release *reprovisionedPVC) error {
+	return p.updateSnapshots(ctx, func(data map[string]string) {
+		delete(data, string(release.Snapshot.UID))
+	})
+}
+
+// updateSnapshots applies the update to the persisted snapshots, creating the ConfigMap when missing
+func (p *PVCReprovisioner) updateSnapshots(ctx context.Context, update func(map[string]string)) error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMap := &v1.ConfigMap{}
+		err := p.Reader.Get(ctx, client.ObjectKey{Namespace: p.Namespace, Name: p.Name}, configMap)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+
+		exists := err == nil
+		if !exists {
+			configMap = &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: p.Namespace,
+					Name:      p.Name,
+				},
+			}
+		}
+		if configMap.Data == nil {
+			configMap.Data = make(map[string]string)
+		}
+
+		// Removing snapshots always goes through, even from a ConfigMap already past the limit
+		size := snapshotsSize(configMap.Data)
+		update(configMap.Data)
+		if updated := snapshotsSize(configMap.Data); updated > size && updated > maxSnapshotsSize {
+			return errors.Errorf("the pvc snapshots ConfigMap - %s/%s would exceed its size limit of %d bytes", p.Namespace, p.Name, maxSnapshotsSize)
+		}
+
+		if exists {
+			return p.Client.Update(ctx, configMap)
+		}
+		return p.Client.Create(ctx, configMap)
+	})
+	if apierrors.IsRequestEntityTooLargeError(err) {
+		return errors.Wrap(err, fmt.Sprintf("the pvc snapshots ConfigMap - %s/%s exceeds its size limit", p.Namespace, p.Name))
+	}
+
+	return err
+}
+
+// reprovision re-creates the PVC once the released one is gone, it reports whether the PVC no longer needs to be tracked
+func (p *PVCReprovisioner) reprovision(ctx context.Context, release *reprovisionedPVC) (bool, error) {
+	snapshot := release.Snapshot
+
+	// A dry-run release never removes the PVC, so the re-creation is reported right away
+	if release.DryRun {
+		p.Recorder.Eventf(snapshot, "Normal", "PVC-Reprovision-DryRun", "The PersistentVolumeClaim %s would have been re-created without its volume", snapshot.Name)
+		p.Collector.ReprovisionedPVC.With(reprovisionLabels(snapshot, true)).Inc()
+		p.Logger.Info(fmt.Sprintf("pvc object - %s would have been re-created", snapshot.GetName()), "Namespace", snapshot.Namespace, "dryrun", true)
+		return true, nil
+	}
+
+	current := &v1.PersistentVolumeClaim{}
+	err := p.Client.Get(ctx, client.ObjectKeyFromObject(snapshot), current)
+	if err == nil {
+		if current.UID == snapshot.UID {
+			return false, nil
+		}
+		p.Logger.Info(fmt.Sprintf("pvc - %s was already re-created and will be skipped", snapshot.Name), "Namespace", snapshot.Namespace)
+		return true, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return false, err
+	}
+
+	pvc := reprovisionedObject(snapshot)
+	if err := p.Client.Create(ctx, pvc); err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			p.Logger.Info(fmt.Sprintf("pvc - %s was already re-created and will be skipped", snapshot.Name), "Namespace", snapshot.Namespace)
+			return true, nil
+		}
+		return false, errors.Wrap(err, fmt.Sprintf("failed to re-create object - %s,", snapshot.GetName()))
+	}
+
+	p.Recorder.Eventf(pvc, "Normal", "PVC-Reprovisioned", "The PersistentVolumeClaim %s was re-created without its volume, so it can bind a volume on a live node", pvc.Name)
+	p.Collector.ReprovisionedPVC.With(reprovisionLabels(pvc, false)).Inc()
+	p.Logger.Info(fmt.Sprintf("pvc object - %s was re-created successfully", pvc.GetName()), "Namespace", pvc.Namespace, "dryrun", false)
+
+	return true, nil
+}
+
+func snapshotsSize(data map[string]string) int {
+	size := 0
+	for key, value := range data {
+		size += len(key) + len(value)
+	}
+
+	return size
+}
+
+// snapshotObject keeps only what the re-created PVC is built from, along with the UID of the released PVC.
+// The status, the managed fields and the resourceVersion are dropped, as they only take room in the shared ConfigMap.
+func snapshotObject(pvc *v1.PersistentVolumeClaim) *v1.PersistentVolumeClaim {
+	snapshot := reprovisionedObject(pvc)
+	snapshot.UID = pvc.UID
+
+	return snapshot
+}
+
+// reprovisionedObject builds the re-created PVC from the snapshot, keeping its spec and metadata without the bound volume
+func reprovisionedObject(snapshot *v1.PersistentVolumeClaim) *v1.PersistentVolumeClaim {
+	pvc := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       snapshot.Namespace,
+			Name:            snapshot.Name,
+			Labels:          snapshot.Labels,
+			Annotations:     make(map[string]string, len(snapshot.Annotations)),
+			OwnerReferences: snapshot.OwnerReferences,
+		},
+		Spec: *snapshot.Spec.DeepCopy(),
+	}
+
+	for key, value := range snapshot.Annotations {
+		pvc.Annotations[key] = value
+	}
+	for _, key := range bindingAnnotations {
+		delete(pvc.Annotations, key)
+	}
+	pvc.Spec.VolumeName = ""
+
+	return pvc
+}
+
+func reprovisionLabels(pvc *v1.PersistentVolumeClaim, dryrun bool) prometheus.Labels {
+	labels := pvcLabels(pvc)
+	labels["dryrun"] = strconv.FormatBool(dryrun)
+
+	return labels
+}
+
+// isStandalonePVC reports whether nothing re-creates the PVC once released, it is neither a generic ephemeral volume
+// nor a StatefulSet volumeClaimTemplate PVC
+func (r *PVCReconciler) isStandalonePVC(ctx context.Context, pvc *v1.PersistentVolumeClaim) (bool, error) {
+	if ephemeralVolumeOwner(pvc) != nil {
+		return false, nil
+	}
+	if owner := metav1.GetControllerOf(pvc); owner != nil && owner.Kind == "StatefulSet" {
+		return false, nil
+	}
+
+	sts, _, err := r.resolveStatefulSet(ctx, pvc)
+	if err != nil {
+		return false, errors.Wrap(err, fmt.Sprintf("failed to resolve the statefulset of object - %s,", pvc.GetName()))
+	}
+
+	return sts == nil, nil
+}
@@ -0,0 +1,141 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+func newTestReprovisioner(r *PVCReconciler, reader client.Reader) *PVCReprovisioner {
+	return &PVCReprovisioner{
+		Client:    r.Client,
+		Reader:    reader,
+		Namespace: "local-pvc-releaser",
+		Name:      "local-pvc-releaser-reprovisioned-pvcs",
+		Recorder:  r.Recorder,
+		Collector: r.Collector,
+		Logger:    r.Logger,
+	}
+}
+
+func persistedSnapshots(t *testing.T, p *PVCReprovisioner) map[string]string {
+	t.Helper()
+
+	configMap := &v1.ConfigMap{}
+	require.NoError(t, p.Reader.Get(context.Background(), client.ObjectKey{Namespace: p.Namespace, Name: p.Name}, configMap))
+
+	return configMap.Data
+}
+
+func TestReprovisionAfterFailover(t *testing.T) {
+	pvc := testPVC("data-0", "pv-0")
+	pvc.Annotations[lastAppliedAnnotation] = "{}"
+	r := newTestReconciler(t, interceptor.Funcs{}, pvc, testLocalPV("pv-0"))
+	reader := r.Client
+
+	// The cache holds the PVCs stripped of their last-applied-configuration, as with --transform-volume-cache
+	r.Client = interceptor.NewClient(r.Client.(client.WithWatch), interceptor.Funcs{
+		List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+			if err := c.List(ctx, list, opts...); err != nil {
+				return err
+			}
+			if pvcs, ok := list.(*v1.PersistentVolumeClaimList); ok {
+				for i := range pvcs.Items {
+					delete(pvcs.Items[i].Annotations, lastAppliedAnnotation)
+				}
+			}
+			return nil
+		},
+	})
+	r.Reprovisioner = newTestReprovisioner(r, reader)
+
+	_, err := r.Reconcile(context.Background(), deleteNode(r))
+	require.NoError(t, err)
+	assert.False(t, pvcExists(t, r, "data-0"))
+	assert.Contains(t, persistedSnapshots(t, r.Reprovisioner), "data-0")
+
+	// The new leader loads the snapshot persisted by the previous one
+	leader := newTestReprovisioner(r, reader)
+	require.NoError(t, leader.load(context.Background()))
+	leader.check(context.Background())
+
+	recreated := &v1.PersistentVolumeClaim{}
+	require.NoError(t, r.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "data-0"}, recreated))
+	assert.Empty(t, recreated.Spec.VolumeName)
+	assert.Equal(t, "{}", recreated.Annotations[lastAppliedAnnotation])
+	assert.NotContains(t, recreated.Annotations, PVCnodeAnnotationKey)
+
+	assert.Empty(t, persistedSnapshots(t, leader))
+	assert.Equal(t, 0, leader.pending.len())
+}
+
+func TestReprovisionDiscardsSnapshotOfFailedRelease(t *testing.T) {
+	r := newTestReconciler(t, interceptor.Funcs{
+		Delete: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+			if _, ok := obj.(*v1.PersistentVolumeClaim); ok {
+				return errors.New("delete failed")
+			}
+			return c.Delete(ctx, obj, opts...)
+		},
+	}, testPVC("data-0", "pv-0"), testLocalPV("pv-0"))
+	r.Reprovisioner = newTestReprovisioner(r, r.Client)
+
+	_, err := r.Reconcile(context.Background(), deleteNode(r))
+	require.Error(t, err)
+	assert.True(t, pvcExists(t, r, "data-0"))
+
+	// The PVC is still there, a later manual removal must not re-create it
+	assert.Empty(t, persistedSnapshots(t, r.Reprovisioner))
+	assert.Equal(t, 0, r.Reprovisioner.pending.len())
+}
+
+func TestReprovisionSnapshotKeepsOnlyTheRecreatedFields(t *testing.T) {
+	pvc := testPVC("data-0", "pv-0")
+	pvc.ResourceVersion = "42"
+	pvc.Labels = map[string]string{"app": "worker"}
+	pvc.ManagedFields = []metav1.ManagedFieldsEntry{{Manager: "kubectl", Operation: metav1.ManagedFieldsOperationApply}}
+	pvc.Status.Phase = v1.ClaimBound
+	r := newTestReconciler(t, interceptor.Funcs{}, pvc)
+	p := newTestReprovisioner(r, r.Client)
+
+	_, err := p.Snapshot(context.Background(), pvc, false)
+	require.NoError(t, err)
+
+	snapshot := &reprovisionedPVC{}
+	require.NoError(t, json.Unmarshal([]byte(persistedSnapshots(t, p)["data-0"]), snapshot))
+	assert.Equal(t, types.UID("data-0"), snapshot.Snapshot.UID)
+	assert.Equal(t, map[string]string{"app": "worker"}, snapshot.Snapshot.Labels)
+	assert.NotContains(t, snapshot.Snapshot.Annotations, PVCnodeAnnotationKey)
+	assert.Empty(t, snapshot.Snapshot.ResourceVersion)
+	assert.Empty(t, snapshot.Snapshot.ManagedFields)
+	assert.Empty(t, snapshot.Snapshot.Status.Phase)
+	assert.Empty(t, snapshot.Snapshot.Spec.VolumeName)
+}
+
+func TestReprovisionSnapshotsSizeLimit(t *testing.T) {
+	p := &PVCReprovisioner{Namespace: "local-pvc-releaser", Name: "local-pvc-releaser-reprovisioned-pvcs"}
+	full := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: p.Namespace, Name: p.Name},
+		Data:       map[string]string{"other": strings.Repeat("x", maxSnapshotsSize)},
+	}
+	r := newTestReconciler(t, interceptor.Funcs{}, full, testPVC("data-0", "pv-0"), testLocalPV("pv-0"))
+	r.Reprovisioner = newTestReprovisioner(r, r.Client)
+
+	_, err := r.Reconcile(context.Background(), deleteNode(r))
+	require.ErrorContains(t, err, "would exceed its size limit")
+
+	// The PVC is not released without its snapshot
+	assert.True(t, pvcExists(t, r, "data-0"))
+}
@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/AppsFlyer/local-pvc-releaser/internal/exporters"
+)
+
+const recoveryCheckInterval = 10 * time.Second
+
+// releasedPVC is a released PVC followed until its workload recovers
+type releasedPVC struct {
+	PVC          types.NamespacedName
+	UID          types.UID
+	TerminatedAt time.Time
+	ReleasedAt   time.Time
+	// stalled is only accessed by the running check
+	stalled bool
+}
+
+// RecoveryTracker follows every released PVC until the workload consuming it recovered.
+// A release recovers once the released PVC is fully gone, the replacement PVC with the same name is Bound
+// and a pod consuming it is Ready. The time from the node removal to the recovery is exported as a histogram,
+// and a warning event is raised when the recovery stalls past the threshold.
+type RecoveryTracker struct {
+	Client         client.Client
+	Recorder       record.EventRecorder
+	Collector      *exporters.Collector
+	Logger         *logr.Logger
+	StallThreshold time.Duration
+
+	leaderOnly
+	released trackedEntries[types.UID, *releasedPVC]
+}
+
+// Track starts following the released PVC, terminatedAt is the time of the node removal that triggered the release
+func (t *RecoveryTracker) Track(pvc *v1.PersistentVolumeClaim, terminatedAt time.Time) {
+	now := time.Now()
+	if terminatedAt.IsZero() {
+		terminatedAt = now
+	}
+
+	t.released.track(pvc.UID, &releasedPVC{
+		PVC:          client.ObjectKeyFromObject(pvc),
+		UID:          pvc.UID,
+		TerminatedAt: terminatedAt,
+		ReleasedAt:   now,
+	})
+}
+
+// Start implements manager.Runnable
+func (t *RecoveryTracker) Start(ctx context.Context) error {
+	return runEvery(ctx, recoveryCheckInterval, t.check)
+}
+
+func (t *RecoveryTracker) check(ctx context.Context) {
+	t.released.checkEach(ctx, t.checkRecovery, func(release *releasedPVC) {
+		t.Logger.Info(fmt.Sprintf("pvc - %s workload did not recover within %s and is no longer tracked", release.PVC.Name, trackingRetention), "Namespace", release.PVC.Namespace)
+	})
+}
+
+// checkRecovery reports whether the workload of the release recovered, raising the stall warning once past the threshold
+func (t *RecoveryTracker) checkRecovery(ctx context.Context, release *releasedPVC) bool {
+	stage, err := t.recoveryStage(ctx, release)
+	if err != nil {
+		t.Logger.Error(err, fmt.Sprintf("failed to check the recovery of pvc - %s", release.PVC.Name))
+		return false
+	}
+
+	if stage == "" {
+		recoveryTime := time.Since(release.TerminatedAt)
+		t.Collector.RecoveryTime.Observe(recoveryTime.Seconds())
+		t.Logger.Info(fmt.Sprintf("pvc - %s workload recovered", release.PVC.Name), "Namespace", release.PVC.Namespace, "RecoveryTime", recoveryTime)
+		return true
+	}
+
+	if !release.stalled && t.StallThreshold > 0 && time.Since(release.ReleasedAt) > t.StallThreshold {
+		release.stalled = true
+		t.stalled(ctx, release, stage)
+	}
+
+	return false
+}
+
+// recoveryStage returns what the release is waiting for, or an empty stage once the workload recovered
+func (t *RecoveryTracker) recoveryStage(ctx context.Context, release *releasedPVC) (string, error) {
+	pvc := &v1.PersistentVolumeClaim{}
+	if err := t.Client.Get(ctx, release.PVC, pvc); err != nil {
+		if apierrors.IsNotFound(err) {
+			return "waiting for the replacement pvc", nil
+		}
+		return "", err
+	}
+	if pvc.UID == release.UID {
+		return "waiting for the released pvc to be deleted", nil
+	}
+	if pvc.Status.Phase != v1.ClaimBound {
+		return "waiting for the replacement pvc to be bound", nil
+	}
+
+	podList := &v1.PodList{}
+	if err := t.Client.List(ctx, podList, client.InNamespace(release.PVC.Namespace)); err != nil {
+		return "", err
+	}
+	for i := range podList.Items {
+		if podReferencesPVC(&podList.Items[i], release.PVC.Name) && podReady(&podList.Items[i]) {
+			return "", nil
+		}
+	}
+
+	return "waiting for the consuming pod to be ready", nil
+}
+
+func (t *RecoveryTracker) stalled(ctx context.Context, release *releasedPVC, stage string) {
+	t.Logger.Info(fmt.Sprintf("pvc - %s workload did not recover within %s", release.PVC.Name, t.StallThreshold), "Namespace", release.PVC.Namespace, "Stage", stage)
+	t.Collector.StalledRecoveries.Inc()
+
+	// The event is recorded on the replacement PVC when it exists, otherwise on the released one
+	pvc := &v1.PersistentVolumeClaim{}
+	if err := t.Client.Get(ctx, release.PVC, pvc); err != nil {
+		pvc.Namespace = release.PVC.Namespace
+		pvc.Name = release.PVC.Name
+		pvc.UID = release.UID
+	}
+	t.Recorder.Eventf(pvc, "Warning", "PVC-Recovery-Stalled", "The workload of the released PersistentVolumeClaim %s did not recover within %s, %s", release.PVC.Name, t.StallThreshold, stage)
+}
+
+func podReady(pod *v1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1.PodReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,143 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func newTestRecoveryTracker(r *PVCReconciler) *RecoveryTracker {
+	return &RecoveryTracker{Client: r.Client, Recorder: r.Recorder, Collector: r.Collector, Logger: r.Logger, StallThreshold: time.Minute}
+}
+
+func replacementPVC(name string, phase v1.PersistentVolumeClaimPhase) *v1.PersistentVolumeClaim {
+	pvc := testPVC(name, "pv-new")
+	pvc.UID = types.UID(name + "-replacement")
+	pvc.Status.Phase = phase
+
+	return pvc
+}
+
+func readyPod(name, claimName string) *v1.Pod {
+	pod := testPod(name, "node-2", claimName)
+	pod.Status.Conditions = []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}}
+
+	return pod
+}
+
+// recoveryTimeSamples returns the number of recoveries observed by the recovery time histogram
+func recoveryTimeSamples(t *testing.T, r *PVCReconciler) uint64 {
+	t.Helper()
+
+	registry := prometheus.NewPedanticRegistry()
+	require.NoError(t, registry.Register(r.Collector.RecoveryTime))
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	require.Len(t, families, 1)
+
+	return families[0].GetMetric()[0].GetHistogram().GetSampleCount()
+}
+
+func TestRecoveryStage(t *testing.T) {
+	tests := []struct {
+		name  string
+		objs  []client.Object
+		stage string
+	}{
+		{
+			name:  "released pvc not deleted yet",
+			objs:  []client.Object{testPVC("data-0", "pv-0")},
+			stage: "waiting for the released pvc to be deleted",
+		},
+		{
+			name:  "no replacement pvc",
+			stage: "waiting for the replacement pvc",
+		},
+		{
+			name:  "replacement pvc pending",
+			objs:  []client.Object{replacementPVC("data-0", v1.ClaimPending)},
+			stage: "waiting for the replacement pvc to be bound",
+		},
+		{
+			name:  "consuming pod not ready",
+			objs:  []client.Object{replacementPVC("data-0", v1.ClaimBound), testPod("worker-0", "node-2", "data-0")},
+			stage: "waiting for the consuming pod to be ready",
+		},
+		{
+			name: "recovered",
+			objs: []client.Object{replacementPVC("data-0", v1.ClaimBound), readyPod("worker-0", "data-0")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestReconciler(t, interceptor.Funcs{}, tt.objs...)
+			tracker := newTestRecoveryTracker(r)
+
+			stage, err := tracker.recoveryStage(context.Background(), &releasedPVC{
+				PVC: types.NamespacedName{Namespace: "default", Name: "data-0"},
+				UID: "data-0",
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tt.stage, stage)
+		})
+	}
+}
+
+func TestRecoveryTrackerObservesRecovery(t *testing.T) {
+	released := testPVC("data-0", "pv-0")
+	r := newTestReconciler(t, interceptor.Funcs{}, released)
+	tracker := newTestRecoveryTracker(r)
+	ctx := context.Background()
+
+	tracker.Track(released, time.Now().Add(-time.Minute))
+	tracker.check(ctx)
+	assert.Equal(t, 1, tracker.released.len())
+
+	// The replacement PVC is bound and consumed by a ready pod
+	require.NoError(t, r.Delete(ctx, released))
+	require.NoError(t, r.Create(ctx, replacementPVC("data-0", v1.ClaimBound)))
+	require.NoError(t, r.Create(ctx, readyPod("worker-0", "data-0")))
+	tracker.check(ctx)
+
+	assert.Equal(t, 0, tracker.released.len())
+	assert.Equal(t, uint64(1), recoveryTimeSamples(t, r))
+	assert.Equal(t, 0.0, testutil.ToFloat64(r.Collector.StalledRecoveries))
+}
+
+func TestRecoveryTrackerWarnsOnceOnStall(t *testing.T) {
+	released := testPVC("data-0", "pv-0")
+	r := newTestReconciler(t, interceptor.Funcs{}, released)
+	tracker := newTestRecoveryTracker(r)
+	ctx := context.Background()
+
+	tracker.Track(released, time.Time{})
+	tracker.check(ctx)
+	assert.Equal(t, 0.0, testutil.ToFloat64(r.Collector.StalledRecoveries))
+
+	// The release is past the stall threshold, the warning is raised once
+	tracker.released.entries[released.UID].value.ReleasedAt = time.Now().Add(-2 * time.Minute)
+	tracker.check(ctx)
+	tracker.check(ctx)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(r.Collector.StalledRecoveries))
+	assert.Equal(t, 1, tracker.released.len())
+	assert.Equal(t, uint64(0), recoveryTimeSamples(t, r))
+
+	events := r.Recorder.(*record.FakeRecorder).Events
+	require.Len(t, events, 1)
+	event := <-events
+	assert.True(t, strings.HasPrefix(event, "Warning PVC-Recovery-Stalled"), event)
+	assert.Contains(t, event, "waiting for the released pvc to be deleted")
+}
@@ -0,0 +1,146 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	releaserv1alpha1 "github.com/AppsFlyer/local-pvc-releaser/api/v1alpha1"
+)
+
+// policyOutcome accumulates the PVCs newly matched and released by a single ReleasePolicy during a CleanPVCS call,
+// to be added to the policy status
+type policyOutcome struct {
+	policy   *releaserv1alpha1.ReleasePolicy
+	matched  int64
+	released int64
+}
+
+// listReleasePolicies returns the ReleasePolicies sorted by name, so the first matching policy is deterministic.
+// An empty list is returned when the ReleasePolicy CRD is not installed on the cluster.
+func (r *PVCReconciler) listReleasePolicies(ctx context.Context) ([]releaserv1alpha1.ReleasePolicy, error) {
+	policyList := &releaserv1alpha1.ReleasePolicyList{}
+	if err := r.List(ctx, policyList); err != nil {
+		if meta.IsNoMatchError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	sort.Slice(policyList.Items, func(i, j int) bool {
+		return policyList.Items[i].Name < policyList.Items[j].Name
+	})
+
+	return policyList.Items, nil
+}
+
+// matchReleasePolicy returns the first policy covering the given PVC, or nil if none of the policies covers it
+func (r *PVCReconciler) matchReleasePolicy(ctx context.Context, policies []releaserv1alpha1.ReleasePolicy, pvc *v1.PersistentVolumeClaim) (*releaserv1alpha1.ReleasePolicy, error) {
+	var namespace *v1.Namespace
+
+	for i := range policies {
+		policy := &policies[i]
+
+		if policy.Spec.NamespaceSelector != nil && namespace == nil {
+			namespace = &v1.Namespace{}
+			if err := r.Get(ctx, client.ObjectKey{Name: pvc.Namespace}, namespace); err != nil {
+				return nil, err
+			}
+		}
+
+		matches, err := policyMatchesPVC(policy, pvc, namespace)
+		if err != nil {
+			return nil, err
+		}
+		if matches {
+			return policy, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func policyMatchesPVC(policy *releaserv1alpha1.ReleasePolicy, pvc *v1.PersistentVolumeClaim, namespace *v1.Namespace) (bool, error) {
+	if policy.Spec.NamespaceSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(policy.Spec.NamespaceSelector)
+		if err != nil {
+			return false, err
+		}
+		if !selector.Matches(labels.Set(namespace.Labels)) {
+			return false, nil
+		}
+	}
+
+	if policy.Spec.PVCSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(policy.Spec.PVCSelector)
+		if err != nil {
+			return false, err
+		}
+		if !selector.Matches(labels.Set(pvc.Labels)) {
+			return false, nil
+		}
+	}
+
+	for _, matcher := range policy.Spec.AnnotationMatchers {
+		value, exists := pvc.Annotations[matcher.Key]
+		if !exists || (matcher.Value != "" && value != matcher.Value) {
+			return false, nil
+		}
+	}
+
+	if len(policy.Spec.StorageClasses) > 0 {
+		storageClassName := ""
+		if pvc.Spec.StorageClassName != nil {
+			storageClassName = *pvc.Spec.StorageClassName
+		}
+
+		allowed := false
+		for _, name := range policy.Spec.StorageClasses {
+			if name == storageClassName {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// updateReleasePolicyStatuses adds the matched and released PVC counters to the status of the policies
+func (r *PVCReconciler) updateReleasePolicyStatuses(ctx context.Context, outcomes map[string]*policyOutcome) {
+	for name, outcome := range outcomes {
+		if outcome.matched == 0 && outcome.released == 0 {
+			continue
+		}
+
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			policy := &releaserv1alpha1.ReleasePolicy{}
+			if err := r.Get(ctx, client.ObjectKey{Name: name}, policy); err != nil {
+				return err
+			}
+
+			policy.Status.MatchedPVCs += outcome.matched
+			policy.Status.ReleasedPVCs += outcome.released
+			if outcome.released > 0 {
+				now := metav1.NewTime(time.Now())
+				policy.Status.LastReleaseTime = &now
+			}
+
+			return r.Status().Update(ctx, policy)
+		})
+		if err != nil {
+			r.Logger.Error(err, fmt.Sprintf("failed to update the status of release policy - %s", name))
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	releaserv1alpha1 "github.com/AppsFlyer/local-pvc-releaser/api/v1alpha1"
+)
+
+func TestReleasePolicyLimitSurvivesRetries(t *testing.T) {
+	limit := int32(1)
+	policy := &releaserv1alpha1.ReleasePolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "one-per-node"},
+		Spec:       releaserv1alpha1.ReleasePolicySpec{MaxReleasesPerNode: &limit},
+	}
+
+	failDelete := true
+	funcs := interceptor.Funcs{
+		Delete: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+			if obj.GetName() == "data-0" && failDelete {
+				return errors.New("delete failed")
+			}
+			return c.Delete(ctx, obj, opts...)
+		},
+	}
+	r := newTestReconciler(t, funcs, policy,
+		testPVC("data-0", "pv-0"), testLocalPV("pv-0"),
+		testPVC("data-1", "pv-1"), testLocalPV("pv-1"),
+		testPVC("data-2", "pv-2"), testLocalPV("pv-2"),
+	)
+	req := deleteNode(r)
+
+	_, err := r.Reconcile(context.Background(), req)
+	require.Error(t, err)
+
+	failDelete = false
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+
+	assert.False(t, pvcExists(t, r, "data-0"))
+	assert.True(t, pvcExists(t, r, "data-1"))
+	assert.True(t, pvcExists(t, r, "data-2"))
+
+	current := &releaserv1alpha1.ReleasePolicy{}
+	require.NoError(t, r.Get(context.Background(), client.ObjectKeyFromObject(policy), current))
+	assert.Equal(t, int64(3), current.Status.MatchedPVCs)
+	assert.Equal(t, int64(1), current.Status.ReleasedPVCs)
+}
+
+func TestReleasePolicyLimitIsPerTermination(t *testing.T) {
+	tracker := newTerminationTracker(0)
+	limit := int32(1)
+	termination := nodeTermination{NodeName: testNode, NodeUID: "node-uid"}
+
+	assert.True(t, tracker.AdmitPolicyRelease(termination, "policy", "data-0", &limit))
+	assert.True(t, tracker.AdmitPolicyRelease(termination, "policy", "data-0", &limit))
+	assert.False(t, tracker.AdmitPolicyRelease(termination, "policy", "data-1", &limit))
+	assert.True(t, tracker.AdmitPolicyRelease(termination, "other-policy", "data-1", &limit))
+
+	// A node re-created with the same name is a new termination
+	recreated := nodeTermination{NodeName: testNode, NodeUID: "new-node-uid"}
+	assert.True(t, tracker.AdmitPolicyRelease(recreated, "policy", "data-1", &limit))
+
+	assert.True(t, tracker.MatchPolicy(recreated, "policy", "data-1"))
+	assert.False(t, tracker.MatchPolicy(recreated, "policy", "data-1"))
+}
@@ -0,0 +1,214 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// statefulSetRecheckInterval is the interval in which releases held by the StatefulSet gate are retried
+const statefulSetRecheckInterval = 30 * time.Second
+
+// releasedReplica is a StatefulSet replica whose PVC was released and that did not recover yet
+type releasedReplica struct {
+	PVC        types.NamespacedName
+	PVCUID     types.UID
+	PodName    string
+	Ordinal    int
+	ReleasedAt time.Time
+}
+
+// recoveringReplicas holds the recovering replicas of a single StatefulSet, a re-created StatefulSet starts over
+type recoveringReplicas struct {
+	UID      types.UID
+	replicas []releasedReplica
+}
+
+// statefulSetGate limits the number of concurrently recovering replicas of every StatefulSet, so quorum based workloads
+// are not rebuilt from empty disks on several replicas at the same time.
+// A replica recovers once its new PVC is Bound and its pod is Ready. A replica that did not recover within the timeout,
+// that was scaled down or whose StatefulSet is gone no longer holds the releases.
+type statefulSetGate struct {
+	mu       sync.Mutex
+	limit    int
+	timeout  time.Duration
+	released map[types.NamespacedName]*recoveringReplicas
+}
+
+func newStatefulSetGate(limit int, timeout time.Duration) *statefulSetGate {
+	return &statefulSetGate{
+		limit:    limit,
+		timeout:  timeout,
+		released: make(map[types.NamespacedName]*recoveringReplicas),
+	}
+}
+
+// Admit reports whether another replica of the StatefulSet may be released, pruning the replicas that no longer recover
+func (g *statefulSetGate) Admit(ctx context.Context, c client.Client, sts *appsv1.StatefulSet) (bool, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if err := g.prune(ctx, c); err != nil {
+		return false, err
+	}
+
+	key := client.ObjectKeyFromObject(sts)
+	tracked, exists := g.released[key]
+	if !exists {
+		return true, nil
+	}
+	if tracked.UID != sts.UID {
+		delete(g.released, key)
+		return true, nil
+	}
+
+	var recovering []releasedReplica
+	for _, replica := range tracked.replicas {
+		if sts.Spec.Replicas != nil && replica.Ordinal >= int(*sts.Spec.Replicas) {
+			continue
+		}
+
+		recovered, err := replicaRecovered(ctx, c, replica)
+		if err != nil {
+			return false, err
+		}
+		if !recovered {
+			recovering = append(recovering, replica)
+		}
+	}
+
+	if len(recovering) == 0 {
+		delete(g.released, key)
+	} else {
+		tracked.replicas = recovering
+	}
+
+	return len(recovering) < g.limit, nil
+}
+
+// Record marks the replica owning the released PVC as recovering
+func (g *statefulSetGate) Record(sts *appsv1.StatefulSet, pvc *v1.PersistentVolumeClaim, podName string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	key := client.ObjectKeyFromObject(sts)
+	tracked, exists := g.released[key]
+	if !exists || tracked.UID != sts.UID {
+		tracked = &recoveringReplicas{UID: sts.UID}
+		g.released[key] = tracked
+	}
+
+	ordinal, _ := strconv.Atoi(strings.TrimPrefix(podName, sts.Name+"-"))
+	tracked.replicas = append(tracked.replicas, releasedReplica{
+		PVC:        client.ObjectKeyFromObject(pvc),
+		PVCUID:     pvc.UID,
+		PodName:    podName,
+		Ordinal:    ordinal,
+		ReleasedAt: time.Now(),
+	})
+}
+
+// prune drops the replicas that did not recover within the timeout, and the StatefulSets that were deleted or re-created
+func (g *statefulSetGate) prune(ctx context.Context, c client.Client) error {
+	for key, tracked := range g.released {
+		sts := &appsv1.StatefulSet{}
+		if err := c.Get(ctx, key, sts); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return err
+			}
+			delete(g.released, key)
+			continue
+		}
+		if sts.UID != tracked.UID {
+			delete(g.released, key)
+			continue
+		}
+
+		var recovering []releasedReplica
+		for _, replica := range tracked.replicas {
+			if g.timeout == 0 || time.Since(replica.ReleasedAt) < g.timeout {
+				recovering = append(recovering, replica)
+			}
+		}
+		if len(recovering) == 0 {
+			delete(g.released, key)
+		} else {
+			tracked.replicas = recovering
+		}
+	}
+
+	return nil
+}
+
+func replicaRecovered(ctx context.Context, c client.Client, replica releasedReplica) (bool, error) {
+	pvc := &v1.PersistentVolumeClaim{}
+	if err := c.Get(ctx, replica.PVC, pvc); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if pvc.UID == replica.PVCUID || pvc.Status.Phase != v1.ClaimBound {
+		return false, nil
+	}
+
+	pod := &v1.Pod{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: replica.PVC.Namespace, Name: replica.PodName}, pod); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return podReady(pod), nil
+}
+
+// resolveStatefulSet returns the StatefulSet owning the PVC and the pod name of its replica.
+// The PVC name is matched against the <volumeClaimTemplate>-<statefulset>-<ordinal> naming, and when the replica pod
+// still exists it must be controlled by that StatefulSet.
+func (r *PVCReconciler) resolveStatefulSet(ctx context.Context, pvc *v1.PersistentVolumeClaim) (*appsv1.StatefulSet, string, error) {
+	stsList := &appsv1.StatefulSetList{}
+	if err := r.List(ctx, stsList, client.InNamespace(pvc.Namespace)); err != nil {
+		return nil, "", err
+	}
+
+	for i := range stsList.Items {
+		sts := &stsList.Items[i]
+
+		for _, template := range sts.Spec.VolumeClaimTemplates {
+			ordinal, found := strings.CutPrefix(pvc.Name, fmt.Sprintf("%s-%s-", template.Name, sts.Name))
+			if !found {
+				continue
+			}
+			if _, err := strconv.Atoi(ordinal); err != nil {
+				continue
+			}
+
+			podName := fmt.Sprintf("%s-%s", sts.Name, ordinal)
+			pod := &v1.Pod{}
+			err := r.Get(ctx, types.NamespacedName{Namespace: pvc.Namespace, Name: podName}, pod)
+			if err != nil && !apierrors.IsNotFound(err) {
+				return nil, "", err
+			}
+			if err == nil {
+				if owner := metav1.GetControllerOf(pod); owner == nil || owner.UID != sts.UID {
+					continue
+				}
+			}
+
+			return sts, podName, nil
+		}
+	}
+
+	return nil, "", nil
+}
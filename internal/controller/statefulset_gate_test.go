@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func testStatefulSet(replicas int32) *appsv1.StatefulSet {
+	return &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "kafka", Namespace: "default", UID: "sts-uid"},
+		Spec:       appsv1.StatefulSetSpec{Replicas: &replicas},
+	}
+}
+
+func TestStatefulSetGateHoldsRecoveringReplica(t *testing.T) {
+	sts := testStatefulSet(3)
+	r := newTestReconciler(t, interceptor.Funcs{}, sts)
+	gate := newStatefulSetGate(1, time.Hour)
+
+	admitted, err := gate.Admit(context.Background(), r.Client, sts)
+	require.NoError(t, err)
+	assert.True(t, admitted)
+
+	gate.Record(sts, testPVC("data-kafka-0", "pv-0"), "kafka-0")
+	admitted, err = gate.Admit(context.Background(), r.Client, sts)
+	require.NoError(t, err)
+	assert.False(t, admitted)
+}
+
+func TestStatefulSetGateExpiresStuckReplica(t *testing.T) {
+	sts := testStatefulSet(3)
+	r := newTestReconciler(t, interceptor.Funcs{}, sts)
+	gate := newStatefulSetGate(1, time.Hour)
+
+	gate.Record(sts, testPVC("data-kafka-0", "pv-0"), "kafka-0")
+	gate.released[types.NamespacedName{Namespace: "default", Name: "kafka"}].replicas[0].ReleasedAt = time.Now().Add(-2 * time.Hour)
+
+	admitted, err := gate.Admit(context.Background(), r.Client, sts)
+	require.NoError(t, err)
+	assert.True(t, admitted)
+	assert.Empty(t, gate.released)
+}
+
+func TestStatefulSetGateDropsScaledDownReplica(t *testing.T) {
+	sts := testStatefulSet(3)
+	r := newTestReconciler(t, interceptor.Funcs{}, sts)
+	gate := newStatefulSetGate(1, time.Hour)
+
+	gate.Record(sts, testPVC("data-kafka-2", "pv-2"), "kafka-2")
+
+	scaled := sts.DeepCopy()
+	replicas := int32(2)
+	scaled.Spec.Replicas = &replicas
+	admitted, err := gate.Admit(context.Background(), r.Client, scaled)
+	require.NoError(t, err)
+	assert.True(t, admitted)
+}
+
+func TestStatefulSetGateDropsDeletedStatefulSet(t *testing.T) {
+	sts := testStatefulSet(3)
+	other := testStatefulSet(3)
+	other.Name, other.UID = "zookeeper", "other-uid"
+	r := newTestReconciler(t, interceptor.Funcs{}, other)
+	gate := newStatefulSetGate(1, time.Hour)
+
+	gate.Record(sts, testPVC("data-kafka-0", "pv-0"), "kafka-0")
+
+	_, err := gate.Admit(context.Background(), r.Client, other)
+	require.NoError(t, err)
+	assert.NotContains(t, gate.released, types.NamespacedName{Namespace: "default", Name: "kafka"})
+
+	// A StatefulSet re-created with the same name starts over
+	gate.Record(other, testPVC("data-zookeeper-0", "pv-0"), "zookeeper-0")
+	recreated := other.DeepCopy()
+	recreated.UID = "recreated-uid"
+	admitted, err := gate.Admit(context.Background(), r.Client, recreated)
+	require.NoError(t, err)
+	assert.True(t, admitted)
+}
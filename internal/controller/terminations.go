@@ -0,0 +1,302 @@
+package controller
+
+import (
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	TerminationSourceEvent        = "event"
+	TerminationSourceNodeDeletion = "node-deletion"
+	TerminationSourceOrphanSweep  = "orphan-sweep"
+
+	// terminationRetention bounds how long a handled node termination is remembered for deduplication.
+	// It matches the default TTL of Kubernetes events, after which a replayed RemovingNode event can no longer show up.
+	terminationRetention = time.Hour
+)
+
+// nodeTermination describes a single signal that a node was removed from the cluster
+type nodeTermination struct {
+	NodeName string
+	NodeUID  types.UID
+	Source   string
+	Time     time.Time
+	// EventUID is the UID of the event that signaled the termination, empty for node deletions
+	EventUID types.UID
+}
+
+// terminationTracker keeps track of node removal signals so the release flow of a node
+// runs once even when both the RemovingNode event and the Node deletion are observed.
+type terminationTracker struct {
+	mu        sync.Mutex
+	retention time.Duration
+	deleted   map[string]nodeTermination
+	released  map[string]nodeTermination
+	failed    map[string]failedRelease
+	policies  map[string]policyTallies
+	cleanups  map[string]pendingCleanups
+}
+
+// failedRelease holds the PVCs whose release failed for a termination, so the retry attempts only them
+type failedRelease struct {
+	termination nodeTermination
+	pvcs        map[types.UID]struct{}
+}
+
+// policyTallies holds the PVCs each ReleasePolicy matched and handed over for release during a termination,
+// so the counters and the per node limit survive the retries and requeues of the release
+type policyTallies struct {
+	termination nodeTermination
+	tallies     map[string]*policyTally
+}
+
+type policyTally struct {
+	matched map[types.UID]struct{}
+	handled map[types.UID]struct{}
+}
+
+// pendingCleanups holds the released PVCs of a termination whose VolumeAttachments cleanup failed,
+// so it is retried before the termination is marked released
+type pendingCleanups struct {
+	termination nodeTermination
+	pvcs        map[types.UID]pendingCleanup
+}
+
+type pendingCleanup struct {
+	pvc    *v1.PersistentVolumeClaim
+	dryrun bool
+}
+
+// newTerminationTracker returns a tracker that remembers terminations for the default retention on top of the release delay
+func newTerminationTracker(releaseDelay time.Duration) *terminationTracker {
+	return &terminationTracker{
+		retention: terminationRetention + releaseDelay,
+		deleted:   make(map[string]nodeTermination),
+		released:  make(map[string]nodeTermination),
+		failed:    make(map[string]failedRelease),
+		policies:  make(map[string]policyTallies),
+		cleanups:  make(map[string]pendingCleanups),
+	}
+}
+
+// ObserveNodeDeletion records a Node deletion seen by the node watch, so it can be reconciled after the object left the cache.
+func (t *terminationTracker) ObserveNodeDeletion(termination nodeTermination) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prune()
+	t.deleted[termination.NodeName] = termination
+}
+
+// DeletedNode returns the deletion recorded for the given node name, if any.
+func (t *terminationTracker) DeletedNode(nodeName string) (nodeTermination, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	termination, exists := t.deleted[nodeName]
+	return termination, exists
+}
+
+// IsReleased reports whether the release flow already completed for the given termination through any signal.
+func (t *terminationTracker) IsReleased(termination nodeTermination) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	released, exists := t.released[termination.NodeName]
+	if !exists {
+		return false
+	}
+
+	// A node that was re-created with the same name gets a new UID and must be handled again
+	return released.NodeUID == "" || termination.NodeUID == "" || released.NodeUID == termination.NodeUID
+}
+
+// SetFailedPVCs records the PVCs whose release failed for the given termination.
+func (t *terminationTracker) SetFailedPVCs(termination nodeTermination, pvcs map[types.UID]struct{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prune()
+	termination.Time = time.Now()
+	t.failed[termination.NodeName] = failedRelease{termination: termination, pvcs: pvcs}
+}
+
+// FailedPVCs returns the PVCs whose release failed for the given termination, nil when there was no failure.
+func (t *terminationTracker) FailedPVCs(termination nodeTermination) map[types.UID]struct{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	failed, exists := t.failed[termination.NodeName]
+	if !exists || (failed.termination.NodeUID != "" && termination.NodeUID != "" && failed.termination.NodeUID != termination.NodeUID) {
+		return nil
+	}
+
+	return failed.pvcs
+}
+
+// ClearFailedPVCs forgets the failed PVCs of the given termination, so the retry attempts all of them.
+func (t *terminationTracker) ClearFailedPVCs(termination nodeTermination) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.failed, termination.NodeName)
+}
+
+// MatchPolicy records the PVC as matched by the given policy for the termination, and reports whether it is the first match
+func (t *terminationTracker) MatchPolicy(termination nodeTermination, policy string, pvc types.UID) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tally := t.policyTally(termination, policy)
+	if _, exists := tally.matched[pvc]; exists {
+		return false
+	}
+	tally.matched[pvc] = struct{}{}
+
+	return true
+}
+
+// AdmitPolicyRelease records the PVC as handed over for release by the given policy for the termination, unless the
+// policy already reached its limit of releases for the node. A PVC already handed over is always admitted again.
+func (t *terminationTracker) AdmitPolicyRelease(termination nodeTermination, policy string, pvc types.UID, limit *int32) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tally := t.policyTally(termination, policy)
+	if _, exists := tally.handled[pvc]; exists {
+		return true
+	}
+	if limit != nil && int32(len(tally.handled)) >= *limit {
+		return false
+	}
+	tally.handled[pvc] = struct{}{}
+
+	return true
+}
+
+// policyTally returns the tally of the given policy for the termination, a node re-created with the same name starts over
+func (t *terminationTracker) policyTally(termination nodeTermination, policy string) *policyTally {
+	tallies, exists := t.policies[termination.NodeName]
+	if !exists || (tallies.termination.NodeUID != "" && termination.NodeUID != "" && tallies.termination.NodeUID != termination.NodeUID) {
+		t.prune()
+		termination.Time = time.Now()
+		tallies = policyTallies{termination: termination, tallies: make(map[string]*policyTally)}
+		t.policies[termination.NodeName] = tallies
+	}
+
+	tally, exists := tallies.tallies[policy]
+	if !exists {
+		tally = &policyTally{matched: make(map[types.UID]struct{}), handled: make(map[types.UID]struct{})}
+		tallies.tallies[policy] = tally
+	}
+
+	return tally
+}
+
+// AddPendingCleanup records the released PVC whose VolumeAttachments cleanup failed for the given termination
+func (t *terminationTracker) AddPendingCleanup(termination nodeTermination, pvc *v1.PersistentVolumeClaim, dryrun bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	cleanups, exists := t.cleanups[termination.NodeName]
+	if !exists || (cleanups.termination.NodeUID != "" && termination.NodeUID != "" && cleanups.termination.NodeUID != termination.NodeUID) {
+		t.prune()
+		termination.Time = time.Now()
+		cleanups = pendingCleanups{termination: termination, pvcs: make(map[types.UID]pendingCleanup)}
+		t.cleanups[termination.NodeName] = cleanups
+	}
+
+	cleanups.pvcs[pvc.UID] = pendingCleanup{pvc: pvc.DeepCopy(), dryrun: dryrun}
+}
+
+// PendingCleanups returns the released PVCs whose VolumeAttachments cleanup is pending for the given termination
+func (t *terminationTracker) PendingCleanups(termination nodeTermination) []pendingCleanup {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	cleanups, exists := t.cleanups[termination.NodeName]
+	if !exists || (cleanups.termination.NodeUID != "" && termination.NodeUID != "" && cleanups.termination.NodeUID != termination.NodeUID) {
+		return nil
+	}
+
+	pending := make([]pendingCleanup, 0, len(cleanups.pvcs))
+	for _, cleanup := range cleanups.pvcs {
+		pending = append(pending, cleanup)
+	}
+
+	return pending
+}
+
+// CompleteCleanup forgets the pending VolumeAttachments cleanup of the given PVC
+func (t *terminationTracker) CompleteCleanup(termination nodeTermination, pvc types.UID) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	cleanups, exists := t.cleanups[termination.NodeName]
+	if !exists {
+		return
+	}
+
+	delete(cleanups.pvcs, pvc)
+	if len(cleanups.pvcs) == 0 {
+		delete(t.cleanups, termination.NodeName)
+	}
+}
+
+// MarkReleased records that the release flow completed for the given termination.
+func (t *terminationTracker) MarkReleased(termination nodeTermination) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prune()
+	termination.Time = time.Now()
+	t.released[termination.NodeName] = termination
+	delete(t.deleted, termination.NodeName)
+	delete(t.failed, termination.NodeName)
+	delete(t.policies, termination.NodeName)
+	delete(t.cleanups, termination.NodeName)
+}
+
+// Forget drops the state of a termination whose release was cancelled without recording it as released,
+// so a later termination of the same node is handled again.
+func (t *terminationTracker) Forget(termination nodeTermination) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.deleted, termination.NodeName)
+	delete(t.failed, termination.NodeName)
+	delete(t.policies, termination.NodeName)
+	delete(t.cleanups, termination.NodeName)
+}
+
+func (t *terminationTracker) prune() {
+	cutoff := time.Now().Add(-t.retention)
+	for name, termination := range t.released {
+		if termination.Time.Before(cutoff) {
+			delete(t.released, name)
+		}
+	}
+	for name, termination := range t.deleted {
+		if termination.Time.Before(cutoff) {
+			delete(t.deleted, name)
+		}
+	}
+	for name, failed := range t.failed {
+		if failed.termination.Time.Before(cutoff) {
+			delete(t.failed, name)
+		}
+	}
+	for name, tallies := range t.policies {
+		if tallies.termination.Time.Before(cutoff) {
+			delete(t.policies, name)
+		}
+	}
+	for name, cleanups := range t.cleanups {
+		if cleanups.termination.Time.Before(cutoff) {
+			delete(t.cleanups, name)
+		}
+	}
+}
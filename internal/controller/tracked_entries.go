@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// trackingRetention is the time after which a release whose follow-up never completed stops being tracked
+const trackingRetention = 24 * time.Hour
+
+// trackedEntries holds what a runnable follows after a release, e.g. a released PVC until its workload recovered.
+// The entries are checked on a copy, so tracking a release never waits for the API calls of a running check.
+type trackedEntries[K comparable, V any] struct {
+	mu      sync.Mutex
+	entries map[K]*trackedEntry[V]
+}
+
+type trackedEntry[V any] struct {
+	value     V
+	trackedAt time.Time
+}
+
+// track starts following the value, replacing the one tracked under the same key
+func (t *trackedEntries[K, V]) track(key K, value V) {
+	t.trackSince(key, value, time.Now())
+}
+
+// trackSince starts following the value as tracked since the given time, e.g. restored after a restart
+func (t *trackedEntries[K, V]) trackSince(key K, value V, trackedAt time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.entries == nil {
+		t.entries = make(map[K]*trackedEntry[V])
+	}
+
+	t.entries[key] = &trackedEntry[V]{value: value, trackedAt: trackedAt}
+}
+
+// len returns the number of tracked entries
+func (t *trackedEntries[K, V]) len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return len(t.entries)
+}
+
+// checkEach calls check on every tracked entry, and stops tracking the ones it reports done and the ones tracked for
+// longer than the retention, on which expired is called. An entry tracked again while being checked is kept.
+func (t *trackedEntries[K, V]) checkEach(ctx context.Context, check func(context.Context, V) bool, expired func(V)) {
+	t.mu.Lock()
+	entries := make(map[K]*trackedEntry[V], len(t.entries))
+	for key, entry := range t.entries {
+		entries[key] = entry
+	}
+	t.mu.Unlock()
+
+	for key, entry := range entries {
+		if check(ctx, entry.value) {
+			t.forget(key, entry)
+			continue
+		}
+
+		if time.Since(entry.trackedAt) > trackingRetention {
+			expired(entry.value)
+			t.forget(key, entry)
+		}
+	}
+}
+
+func (t *trackedEntries[K, V]) forget(key K, entry *trackedEntry[V]) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.entries[key] == entry {
+		delete(t.entries, key)
+	}
+}
+
+// runEvery calls fn on every interval until the context is done
+func runEvery(ctx context.Context, interval time.Duration, fn func(context.Context)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			fn(ctx)
+		}
+	}
+}
+
+// leaderOnly implements manager.LeaderElectionRunnable for the runnables following the releases, as only the leader
+// releases PVCs and knows what to follow
+type leaderOnly struct{}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable
+func (leaderOnly) NeedLeaderElection() bool {
+	return true
+}
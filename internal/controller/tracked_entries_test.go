@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrackedEntriesCheckDoesNotHoldTheLock(t *testing.T) {
+	var entries trackedEntries[string, int]
+	entries.track("pv-0", 0)
+
+	entries.checkEach(context.Background(), func(ctx context.Context, value int) bool {
+		// Tracking from within the check would deadlock if the lock were held during the check
+		entries.track("pv-1", 1)
+		entries.track("pv-0", 2)
+		return true
+	}, func(int) {})
+
+	// pv-0 was tracked again while being checked, so it is kept
+	assert.Equal(t, 2, entries.len())
+}
+
+func TestTrackedEntriesRetention(t *testing.T) {
+	var entries trackedEntries[string, int]
+	entries.track("pv-0", 0)
+	entries.track("pv-1", 1)
+	entries.entries["pv-0"].trackedAt = time.Now().Add(-trackingRetention - time.Minute)
+
+	var expired []int
+	entries.checkEach(context.Background(), func(context.Context, int) bool { return false }, func(value int) {
+		expired = append(expired, value)
+	})
+
+	assert.Equal(t, []int{0}, expired)
+	assert.Equal(t, 1, entries.len())
+}
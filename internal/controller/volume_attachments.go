@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// cleanVolumeAttachments removes the VolumeAttachments of the released PVC volume on the removed node.
+// The attacher of a dead node never detaches the volume, so their finalizers are removed before they are deleted,
+// otherwise the attach/detach controller waits on them and delays the recovery.
+func (r *PVCReconciler) cleanVolumeAttachments(ctx context.Context, pvc *v1.PersistentVolumeClaim, dryrun bool) error {
+	if pvc.Spec.VolumeName == "" {
+		return nil
+	}
+
+	attachmentList := &storagev1.VolumeAttachmentList{}
+	if err := r.List(ctx, attachmentList, client.MatchingFields{VolumeAttachmentPVIndex: pvc.Spec.VolumeName}); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to list the volume attachments of object - %s,", pvc.GetName()))
+	}
+
+	var patchOpts []client.PatchOption
+	var deleteOpts []client.DeleteOption
+	if dryrun && !r.DryRun {
+		patchOpts = append(patchOpts, client.DryRunAll)
+		deleteOpts = append(deleteOpts, client.DryRunAll)
+	}
+	dryrun = dryrun || r.DryRun
+
+	for i := range attachmentList.Items {
+		attachment := &attachmentList.Items[i]
+
+		removed, err := r.nodeRemoved(ctx, attachment.Spec.NodeName)
+		if err != nil {
+			return err
+		}
+		if !removed {
+			r.Logger.Info(fmt.Sprintf("volume attachment - %s of pvc - %s is not attached to a removed node and will be skipped", attachment.Name, pvc.Name))
+			continue
+		}
+
+		if len(attachment.Finalizers) > 0 {
+			patch := client.MergeFrom(attachment.DeepCopy())
+			attachment.Finalizers = nil
+			if err := r.Patch(ctx, attachment, patch, patchOpts...); err != nil {
+				if apierrors.IsNotFound(err) {
+					continue
+				}
+				return errors.Wrap(err, fmt.Sprintf("failed to remove the finalizers of volume attachment - %s,", attachment.GetName()))
+			}
+		}
+
+		if err := r.Delete(ctx, attachment, deleteOpts...); err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrap(err, fmt.Sprintf("failed to delete volume attachment - %s,", attachment.GetName()))
+		}
+
+		if dryrun {
+			r.Recorder.Eventf(pvc, "Normal", "VolumeAttachment-Delete-DryRun", "The VolumeAttachment %s of PersistentVolume %s on removed node %s would have been deleted", attachment.Name, pvc.Spec.VolumeName, attachment.Spec.NodeName)
+		} else {
+			r.Recorder.Eventf(pvc, "Normal", "VolumeAttachment-Deleted", "The VolumeAttachment %s of PersistentVolume %s on removed node %s was deleted", attachment.Name, pvc.Spec.VolumeName, attachment.Spec.NodeName)
+		}
+		r.Collector.DeletedVolumeAttachments.With(prometheus.Labels{"dryrun": strconv.FormatBool(dryrun)}).Inc()
+
+		r.Logger.Info(fmt.Sprintf("volume attachment object - %s on removed node - %s was deleted", attachment.GetName(), attachment.Spec.NodeName), "dryrun", dryrun)
+	}
+
+	return nil
+}
+
+// retryPendingCleanups retries the failed VolumeAttachments cleanups of the termination's released PVCs
+func (r *PVCReconciler) retryPendingCleanups(ctx context.Context, termination nodeTermination) error {
+	var errs []error
+	for _, cleanup := range r.tracker.PendingCleanups(termination) {
+		if err := r.cleanVolumeAttachments(ctx, cleanup.pvc, cleanup.dryrun); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		r.tracker.CompleteCleanup(termination, cleanup.pvc.UID)
+	}
+
+	if err := utilerrors.NewAggregate(errs); err != nil {
+		r.Logger.Error(err, fmt.Sprintf("failed to clean the volume attachments of node - %s released pvc objects", termination.NodeName))
+		return err
+	}
+
+	return nil
+}
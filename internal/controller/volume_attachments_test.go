@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func testVolumeAttachment(name, nodeName, pvName string) *storagev1.VolumeAttachment {
+	return &storagev1.VolumeAttachment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Finalizers: []string{"external-attacher/local-csi"}},
+		Spec: storagev1.VolumeAttachmentSpec{
+			Attacher: "local-csi",
+			NodeName: nodeName,
+			Source:   storagev1.VolumeAttachmentSource{PersistentVolumeName: &pvName},
+		},
+	}
+}
+
+func volumeAttachmentExists(t *testing.T, r *PVCReconciler, name string) bool {
+	t.Helper()
+
+	err := r.Get(context.Background(), client.ObjectKey{Name: name}, &storagev1.VolumeAttachment{})
+	if apierrors.IsNotFound(err) {
+		return false
+	}
+	require.NoError(t, err)
+
+	return true
+}
+
+func TestCleanVolumeAttachments(t *testing.T) {
+	r := newTestReconciler(t, interceptor.Funcs{},
+		testPVC("data-0", "pv-0"), testLocalPV("pv-0"),
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-2"}},
+		testVolumeAttachment("csi-removed", testNode, "pv-0"),
+		testVolumeAttachment("csi-live", "node-2", "pv-0"),
+	)
+	r.CleanVolumeAttachments = true
+
+	_, err := r.Reconcile(context.Background(), deleteNode(r))
+	require.NoError(t, err)
+
+	assert.False(t, volumeAttachmentExists(t, r, "csi-removed"))
+	assert.True(t, volumeAttachmentExists(t, r, "csi-live"))
+}
+
+func TestCleanVolumeAttachmentsRetriesFailures(t *testing.T) {
+	failDelete := true
+	funcs := interceptor.Funcs{
+		Delete: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+			if _, ok := obj.(*storagev1.VolumeAttachment); ok && failDelete {
+				return errors.New("delete failed")
+			}
+			return c.Delete(ctx, obj, opts...)
+		},
+	}
+	r := newTestReconciler(t, funcs,
+		testPVC("data-0", "pv-0"), testLocalPV("pv-0"),
+		testVolumeAttachment("csi-removed", testNode, "pv-0"),
+	)
+	r.CleanVolumeAttachments = true
+	req := deleteNode(r)
+
+	_, err := r.Reconcile(context.Background(), req)
+	require.Error(t, err)
+	assert.False(t, pvcExists(t, r, "data-0"))
+	assert.False(t, r.tracker.IsReleased(nodeTermination{NodeName: testNode}))
+
+	// The released PVC is no longer found, the pending cleanup is retried on its own
+	failDelete = false
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	assert.False(t, volumeAttachmentExists(t, r, "csi-removed"))
+	assert.True(t, r.tracker.IsReleased(nodeTermination{NodeName: testNode}))
+}
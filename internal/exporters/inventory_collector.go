@@ -0,0 +1,103 @@
+package exporters
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const inventoryListTimeout = 10 * time.Second
+
+// LocatePVCFunc resolves the node holding the storage of a PVC, and whether that storage is node-local
+type LocatePVCFunc func(ctx context.Context, pvc *v1.PersistentVolumeClaim) (string, bool, error)
+
+type inventoryKey struct {
+	node         string
+	namespace    string
+	storageClass string
+}
+
+// InventoryCollector exports the node-local PVCs and their requested capacity per node, namespace and storage class.
+// The inventory is computed on every scrape from the informer cache, so it is always up to date.
+type InventoryCollector struct {
+	Reader client.Reader
+	Locate LocatePVCFunc
+	Logger *logr.Logger
+
+	pvcs     *prometheus.Desc
+	capacity *prometheus.Desc
+}
+
+func NewInventoryCollector(reader client.Reader, locate LocatePVCFunc, logger *logr.Logger) *InventoryCollector {
+	labels := []string{"node", "namespace", "storage_class"}
+
+	return &InventoryCollector{
+		Reader: reader,
+		Locate: locate,
+		Logger: logger,
+		pvcs: prometheus.NewDesc(
+			"local_pvc_inventory",
+			"Represents the number of node-local PVCs held by a node.",
+			labels, nil,
+		),
+		capacity: prometheus.NewDesc(
+			"local_pvc_inventory_capacity_bytes",
+			"Represents the requested capacity of the node-local PVCs held by a node.",
+			labels, nil,
+		),
+	}
+}
+
+func (c *InventoryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.pvcs
+	ch <- c.capacity
+}
+
+func (c *InventoryCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), inventoryListTimeout)
+	defer cancel()
+
+	pvcList := &v1.PersistentVolumeClaimList{}
+	if err := c.Reader.List(ctx, pvcList); err != nil {
+		c.Logger.Error(err, "failed to list pvc objects for the local pvc inventory")
+		return
+	}
+
+	counts := make(map[inventoryKey]float64)
+	capacities := make(map[inventoryKey]float64)
+
+	for i := range pvcList.Items {
+		pvc := &pvcList.Items[i]
+		if pvc.Spec.VolumeName == "" || pvc.DeletionTimestamp != nil {
+			continue
+		}
+
+		nodeName, local, err := c.Locate(ctx, pvc)
+		if err != nil {
+			c.Logger.Error(err, "failed to locate pvc object for the local pvc inventory", "PVC", client.ObjectKeyFromObject(pvc))
+			continue
+		}
+		if !local || nodeName == "" {
+			continue
+		}
+
+		key := inventoryKey{node: nodeName, namespace: pvc.Namespace}
+		if pvc.Spec.StorageClassName != nil {
+			key.storageClass = *pvc.Spec.StorageClassName
+		}
+
+		counts[key]++
+		if request, exists := pvc.Spec.Resources.Requests[v1.ResourceStorage]; exists {
+			capacities[key] += request.AsApproximateFloat64()
+		}
+	}
+
+	for key, count := range counts {
+		ch <- prometheus.MustNewConstMetric(c.pvcs, prometheus.GaugeValue, count, key.node, key.namespace, key.storageClass)
+		ch <- prometheus.MustNewConstMetric(c.capacity, prometheus.GaugeValue, capacities[key], key.node, key.namespace, key.storageClass)
+	}
+}
@@ -0,0 +1,56 @@
+package exporters
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func inventoryPVC(name, volumeName, size string) *v1.PersistentVolumeClaim {
+	storageClass := "local-storage"
+
+	return &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "kafka"},
+		Spec: v1.PersistentVolumeClaimSpec{
+			StorageClassName: &storageClass,
+			VolumeName:       volumeName,
+			Resources: v1.VolumeResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse(size)},
+			},
+		},
+	}
+}
+
+func TestInventoryCollector(t *testing.T) {
+	reader := fake.NewClientBuilder().WithObjects(
+		inventoryPVC("data-kafka-0", "pv-0", "1Gi"),
+		inventoryPVC("data-kafka-1", "pv-1", "2Gi"),
+		inventoryPVC("data-kafka-2", "pv-remote", "4Gi"),
+		inventoryPVC("data-kafka-3", "", "8Gi"),
+	).Build()
+
+	locate := func(_ context.Context, pvc *v1.PersistentVolumeClaim) (string, bool, error) {
+		return "node-1", pvc.Spec.VolumeName != "pv-remote", nil
+	}
+	logger := logr.Discard()
+
+	collector := NewInventoryCollector(reader, locate, &logger)
+
+	expected := `
+# HELP local_pvc_inventory Represents the number of node-local PVCs held by a node.
+# TYPE local_pvc_inventory gauge
+local_pvc_inventory{namespace="kafka",node="node-1",storage_class="local-storage"} 2
+# HELP local_pvc_inventory_capacity_bytes Represents the requested capacity of the node-local PVCs held by a node.
+# TYPE local_pvc_inventory_capacity_bytes gauge
+local_pvc_inventory_capacity_bytes{namespace="kafka",node="node-1",storage_class="local-storage"} 3.221225472e+09
+`
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected)))
+}
@@ -4,8 +4,46 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+// Skip reasons of the pvc_skipped metric
+const (
+	SkipReasonNotLocal         = "not-local"
+	SkipReasonSelectorMismatch = "selector-mismatch"
+	SkipReasonPVMissing        = "pv-missing"
+	SkipReasonPolicyBlocked    = "policy-blocked"
+	SkipReasonUnbound          = "unbound"
+)
+
 type Collector struct {
-	DeletedPVC *prometheus.CounterVec
+	DeletedPVC   *prometheus.CounterVec
+	OrphanSweeps prometheus.Counter
+	OrphanPVCs   *prometheus.CounterVec
+
+	CancelledRelease prometheus.Counter
+	RefusedRelease   prometheus.Counter
+
+	CircuitBreakerTrips *prometheus.CounterVec
+	CircuitBreakerOpen  prometheus.Gauge
+
+	ForceDeletedPods       *prometheus.CounterVec
+	ForceDeletePodFailures prometheus.Counter
+
+	RecoveryTime      prometheus.Histogram
+	StalledRecoveries prometheus.Counter
+
+	EvaluatedPVC   *prometheus.CounterVec
+	ReleasedPVC    *prometheus.CounterVec
+	SkippedPVC     *prometheus.CounterVec
+	FailedPVC      *prometheus.CounterVec
+	ReleaseLatency *prometheus.HistogramVec
+	ReleaseRetries *prometheus.CounterVec
+
+	PreconditionConflicts *prometheus.CounterVec
+	RescheduledPVC        *prometheus.CounterVec
+
+	CleanedPV                *prometheus.CounterVec
+	DeletedVolumeAttachments *prometheus.CounterVec
+	EphemeralPVCReleased     *prometheus.CounterVec
+	ReprovisionedPVC         *prometheus.CounterVec
 }
 
 func NewCollector() *Collector {
@@ -17,15 +55,208 @@ func NewCollector() *Collector {
 			},
 			[]string{"dryrun"},
 		),
+		OrphanSweeps: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "pvc_orphan_sweeps",
+				Help: "Represents the number of orphan PVC sweeps that were executed.",
+			},
+		),
+		OrphanPVCs: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "pvc_orphans_detected",
+				Help: "Represents the number of local PVCs found pinned to a nonexistent node by the orphan sweeper.",
+			},
+			[]string{"dryrun"},
+		),
+		CancelledRelease: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "pvc_release_cancelled",
+				Help: "Represents the number of node releases cancelled as the node came back within the grace period.",
+			},
+		),
+		RefusedRelease: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "pvc_release_refused",
+				Help: "Represents the number of node releases refused as the terminated node still exists or is Ready.",
+			},
+		),
+		CircuitBreakerTrips: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "circuit_breaker_trips",
+				Help: "Represents the number of times the circuit breaker tripped on an exceeded blast radius limit.",
+			},
+			[]string{"reason"},
+		),
+		CircuitBreakerOpen: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "circuit_breaker_open",
+				Help: "Represents whether the circuit breaker is open and all PVC deletions are stopped.",
+			},
+		),
+		ForceDeletedPods: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "pod_force_deleted",
+				Help: "Represents the number of pods on removed nodes force deleted to release their PVCs.",
+			},
+			[]string{"dryrun"},
+		),
+		ForceDeletePodFailures: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "pod_force_delete_failures",
+				Help: "Represents the number of pods on removed nodes that failed to be force deleted.",
+			},
+		),
+		RecoveryTime: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "pvc_recovery_seconds",
+				Help:    "Represents the time from the node removal until the workload of a released PVC recovered.",
+				Buckets: prometheus.ExponentialBuckets(30, 2, 10),
+			},
+		),
+		StalledRecoveries: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "pvc_recovery_stalled",
+				Help: "Represents the number of released PVCs whose workload did not recover within the stall threshold.",
+			},
+		),
+		EvaluatedPVC: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "pvc_evaluated",
+				Help: "Represents the number of PVCs of removed nodes evaluated for a release.",
+			},
+			[]string{"namespace", "storage_class"},
+		),
+		ReleasedPVC: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "pvc_released",
+				Help: "Represents the number of PVCs released.",
+			},
+			[]string{"namespace", "storage_class", "dryrun"},
+		),
+		SkippedPVC: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "pvc_skipped",
+				Help: "Represents the number of evaluated PVCs that were not released, by the skip reason.",
+			},
+			[]string{"namespace", "storage_class", "reason"},
+		),
+		FailedPVC: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "pvc_release_failed",
+				Help: "Represents the number of PVC releases that failed.",
+			},
+			[]string{"namespace", "storage_class"},
+		),
+		ReleaseLatency: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "pvc_release_latency_seconds",
+				Help:    "Represents the time from the node termination until the release of its PVCs.",
+				Buckets: prometheus.ExponentialBuckets(1, 2, 14),
+			},
+			[]string{"namespace", "storage_class"},
+		),
+		ReleaseRetries: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "pvc_release_retries",
+				Help: "Represents the number of retried PVC releases after a failure.",
+			},
+			[]string{"namespace", "storage_class"},
+		),
+		PreconditionConflicts: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "pvc_delete_precondition_conflicts",
+				Help: "Represents the number of PVC deletes rejected as the PVC was re-created or changed since it was evaluated.",
+			},
+			[]string{"namespace", "storage_class"},
+		),
+		RescheduledPVC: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "pvc_rescheduled",
+				Help: "Represents the number of unbound PVCs whose stale selected node was cleared.",
+			},
+			[]string{"namespace", "storage_class", "dryrun"},
+		),
+		CleanedPV: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "pv_cleaned",
+				Help: "Represents the number of orphaned node-local PVs cleaned up after their PVC was released.",
+			},
+			[]string{"action", "dryrun"},
+		),
+		DeletedVolumeAttachments: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "volume_attachment_deleted",
+				Help: "Represents the number of VolumeAttachments of released PVCs deleted from a removed node.",
+			},
+			[]string{"dryrun"},
+		),
+		EphemeralPVCReleased: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "ephemeral_pvc_released",
+				Help: "Represents the number of generic ephemeral volume PVCs released by deleting their owning pod.",
+			},
+			[]string{"namespace", "storage_class", "dryrun"},
+		),
+		ReprovisionedPVC: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "pvc_reprovisioned",
+				Help: "Represents the number of released standalone PVCs that were re-created without their volume.",
+			},
+			[]string{"namespace", "storage_class", "dryrun"},
+		),
 	}
 }
 
 // Collect implements Collector
 func (c *Collector) Collect(ch chan<- prometheus.Metric) {
 	c.DeletedPVC.Collect(ch)
+	c.OrphanSweeps.Collect(ch)
+	c.OrphanPVCs.Collect(ch)
+	c.CancelledRelease.Collect(ch)
+	c.RefusedRelease.Collect(ch)
+	c.CircuitBreakerTrips.Collect(ch)
+	c.CircuitBreakerOpen.Collect(ch)
+	c.ForceDeletedPods.Collect(ch)
+	c.ForceDeletePodFailures.Collect(ch)
+	c.RecoveryTime.Collect(ch)
+	c.StalledRecoveries.Collect(ch)
+	c.EvaluatedPVC.Collect(ch)
+	c.ReleasedPVC.Collect(ch)
+	c.SkippedPVC.Collect(ch)
+	c.FailedPVC.Collect(ch)
+	c.ReleaseLatency.Collect(ch)
+	c.ReleaseRetries.Collect(ch)
+	c.PreconditionConflicts.Collect(ch)
+	c.RescheduledPVC.Collect(ch)
+	c.CleanedPV.Collect(ch)
+	c.DeletedVolumeAttachments.Collect(ch)
+	c.EphemeralPVCReleased.Collect(ch)
+	c.ReprovisionedPVC.Collect(ch)
 }
 
 // Describe implements Collector
 func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
 	c.DeletedPVC.Describe(ch)
+	c.OrphanSweeps.Describe(ch)
+	c.OrphanPVCs.Describe(ch)
+	c.CancelledRelease.Describe(ch)
+	c.RefusedRelease.Describe(ch)
+	c.CircuitBreakerTrips.Describe(ch)
+	c.CircuitBreakerOpen.Describe(ch)
+	c.ForceDeletedPods.Describe(ch)
+	c.ForceDeletePodFailures.Describe(ch)
+	c.RecoveryTime.Describe(ch)
+	c.StalledRecoveries.Describe(ch)
+	c.EvaluatedPVC.Describe(ch)
+	c.ReleasedPVC.Describe(ch)
+	c.SkippedPVC.Describe(ch)
+	c.FailedPVC.Describe(ch)
+	c.ReleaseLatency.Describe(ch)
+	c.ReleaseRetries.Describe(ch)
+	c.PreconditionConflicts.Describe(ch)
+	c.RescheduledPVC.Describe(ch)
+	c.CleanedPV.Describe(ch)
+	c.DeletedVolumeAttachments.Describe(ch)
+	c.EphemeralPVCReleased.Describe(ch)
+	c.ReprovisionedPVC.Describe(ch)
 }
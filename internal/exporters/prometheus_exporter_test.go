@@ -2,13 +2,21 @@ package exporters
 
 import (
 	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func TestNewCollector(t *testing.T) {
 	collector := NewCollector()
 
-	// Verify that the DeletedPVC counter is not nil
-	if collector.DeletedPVC == nil {
-		t.Errorf("Expected DeletedPVC counter to be initialized, got nil")
+	// Every metric must be described and collected without conflicting with another one
+	if err := prometheus.NewPedanticRegistry().Register(collector); err != nil {
+		t.Errorf("Expected the collector to register, got %v", err)
+	}
+
+	collector.DeletedPVC.With(prometheus.Labels{"dryrun": "false"}).Inc()
+	if value := testutil.ToFloat64(collector.DeletedPVC.With(prometheus.Labels{"dryrun": "false"})); value != 1 {
+		t.Errorf("Expected DeletedPVC to be 1, got %v", value)
 	}
 }
@@ -0,0 +1,268 @@
+package triggers
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	RemovingNodeReason      = "RemovingNode"
+	NodeControllerComponent = "node-controller"
+	NodeKind                = "Node"
+
+	NodeFieldName      = "name"
+	NodeFieldNamespace = "namespace"
+	NodeFieldFieldPath = "fieldPath"
+)
+
+// Rule matches a Kubernetes event that signals a node termination.
+// Empty matchers accept any value, the reason is mandatory.
+type Rule struct {
+	Reason             string `json:"reason"`
+	SourceComponent    string `json:"sourceComponent,omitempty"`
+	InvolvedObjectKind string `json:"involvedObjectKind,omitempty"`
+	MessageRegex       string `json:"messageRegex,omitempty"`
+	// NodeField names the involved-object field holding the terminated node name (name, namespace or fieldPath)
+	NodeField string `json:"nodeField,omitempty"`
+
+	message *regexp.Regexp
+}
+
+// Config is the structure of the trigger rules config file
+type Config struct {
+	Rules []Rule `json:"rules"`
+}
+
+// RuleSet is an ordered list of compiled rules, the first matching rule wins
+type RuleSet struct {
+	rules []Rule
+}
+
+// DefaultRules returns the rule matching the RemovingNode event generated by the Kubernetes node controller
+func DefaultRules() []Rule {
+	return []Rule{
+		{
+			Reason:             RemovingNodeReason,
+			SourceComponent:    NodeControllerComponent,
+			InvolvedObjectKind: NodeKind,
+			NodeField:          NodeFieldName,
+		},
+	}
+}
+
+// NewRuleSet validates and compiles the given rules, falling back to the default rules when none are given
+func NewRuleSet(rules []Rule) (*RuleSet, error) {
+	if len(rules) == 0 {
+		rules = DefaultRules()
+	}
+
+	compiled := make([]Rule, 0, len(rules))
+	for i, rule := range rules {
+		if rule.Reason == "" {
+			return nil, errors.Errorf("trigger rule #%d is missing a reason", i)
+		}
+
+		if rule.NodeField == "" {
+			rule.NodeField = NodeFieldName
+		}
+		if rule.NodeField != NodeFieldName && rule.NodeField != NodeFieldNamespace && rule.NodeField != NodeFieldFieldPath {
+			return nil, errors.Errorf("trigger rule #%d has an unsupported node field - %s", i, rule.NodeField)
+		}
+
+		if rule.MessageRegex != "" {
+			message, err := regexp.Compile(rule.MessageRegex)
+			if err != nil {
+				return nil, errors.Wrap(err, fmt.Sprintf("trigger rule #%d has an invalid message regex", i))
+			}
+			rule.message = message
+		}
+
+		compiled = append(compiled, rule)
+	}
+
+	return &RuleSet{rules: compiled}, nil
+}
+
+// Rules returns the compiled rules of the set
+func (s *RuleSet) Rules() []Rule {
+	return s.rules
+}
+
+// Match returns the name and UID of the terminated node when the event matches one of the rules.
+// The UID is only known when the involved object is the node itself.
+func (s *RuleSet) Match(e *v1.Event) (string, types.UID, bool) {
+	for _, rule := range s.rules {
+		if !rule.matches(e) {
+			continue
+		}
+
+		switch rule.NodeField {
+		case NodeFieldNamespace:
+			return e.InvolvedObject.Namespace, "", e.InvolvedObject.Namespace != ""
+		case NodeFieldFieldPath:
+			return e.InvolvedObject.FieldPath, "", e.InvolvedObject.FieldPath != ""
+		default:
+			var uid types.UID
+			if e.InvolvedObject.Kind == NodeKind {
+				uid = e.InvolvedObject.UID
+			}
+			return e.InvolvedObject.Name, uid, e.InvolvedObject.Name != ""
+		}
+	}
+
+	return "", "", false
+}
+
+// FieldSelector returns the event field selector shared by all the rules, so the event cache holds only the events
+// the rules may match. A field is selected only when every rule requires the same value for it.
+func (s *RuleSet) FieldSelector() fields.Selector {
+	common := map[string]string{
+		"reason":              s.rules[0].Reason,
+		"source":              s.rules[0].SourceComponent,
+		"involvedObject.kind": s.rules[0].InvolvedObjectKind,
+	}
+
+	for _, rule := range s.rules[1:] {
+		if rule.Reason != common["reason"] {
+			delete(common, "reason")
+		}
+		if rule.SourceComponent != common["source"] {
+			delete(common, "source")
+		}
+		if rule.InvolvedObjectKind != common["involvedObject.kind"] {
+			delete(common, "involvedObject.kind")
+		}
+	}
+
+	selected := fields.Set{}
+	for field, value := range common {
+		if value != "" {
+			selected[field] = value
+		}
+	}
+
+	return fields.SelectorFromSet(selected)
+}
+
+func (r *Rule) matches(e *v1.Event) bool {
+	if e.Reason != r.Reason {
+		return false
+	}
+	if r.SourceComponent != "" && e.Source.Component != r.SourceComponent {
+		return false
+	}
+	if r.InvolvedObjectKind != "" && e.InvolvedObject.Kind != r.InvolvedObjectKind {
+		return false
+	}
+	if r.message != nil && !r.message.MatchString(e.Message) {
+		return false
+	}
+
+	return true
+}
+
+// Load builds a rule set out of the rules config file and the rules given on the command line.
+// The default rules are used only when no rules are configured at all.
+func Load(path string, flagRules []string) (*RuleSet, error) {
+	var rules []Rule
+
+	if path != "" {
+		fileRules, err := LoadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, fileRules...)
+	}
+
+	for _, value := range flagRules {
+		rule, err := ParseRule(value)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+
+	return NewRuleSet(rules)
+}
+
+// LoadFile reads trigger rules from a YAML or JSON config file
+func LoadFile(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to read trigger rules file - %s", path))
+	}
+
+	config := &Config{}
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to parse trigger rules file - %s", path))
+	}
+
+	return config.Rules, nil
+}
+
+// ParseRule parses a rule given as a comma separated list of key=value pairs, e.g.
+// reason=DeletingNode,source=cloud-node-lifecycle-controller,kind=Node,message=^Deleting node,nodeField=name
+// A comma is treated as a separator only when it is followed by one of the known keys,
+// so the message regex may contain commas as well.
+func ParseRule(value string) (Rule, error) {
+	rule := Rule{}
+
+	for _, pair := range splitRuleFields(value) {
+		key, val, found := strings.Cut(pair, "=")
+		if !found {
+			return rule, errors.Errorf("invalid trigger rule field - %q, expected key=value", pair)
+		}
+
+		switch strings.TrimSpace(key) {
+		case "reason":
+			rule.Reason = val
+		case "source":
+			rule.SourceComponent = val
+		case "kind":
+			rule.InvolvedObjectKind = val
+		case "message":
+			rule.MessageRegex = val
+		case "nodeField":
+			rule.NodeField = val
+		default:
+			return rule, errors.Errorf("unknown trigger rule field - %q", key)
+		}
+	}
+
+	return rule, nil
+}
+
+var ruleFieldSeparator = regexp.MustCompile(`,\s*(reason|source|kind|message|nodeField)=`)
+
+func splitRuleFields(value string) []string {
+	var fields []string
+
+	start := 0
+	for _, loc := range ruleFieldSeparator.FindAllStringIndex(value, -1) {
+		fields = append(fields, value[start:loc[0]])
+		start = loc[0] + 1
+	}
+	fields = append(fields, strings.TrimSpace(value[start:]))
+
+	return fields
+}
+
+// RuleFlag collects repeated --trigger-rule command line flags
+type RuleFlag []string
+
+func (f *RuleFlag) String() string {
+	return strings.Join(*f, " ")
+}
+
+func (f *RuleFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
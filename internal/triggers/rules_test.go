@@ -0,0 +1,73 @@
+package triggers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestParseRule(t *testing.T) {
+	rule, err := ParseRule("reason=DeletingNode,source=cloud-node-lifecycle-controller,kind=Node,message=^Deleting node [a-z]{1,3},x,nodeField=name")
+	assert.NoError(t, err)
+	assert.Equal(t, "DeletingNode", rule.Reason)
+	assert.Equal(t, "cloud-node-lifecycle-controller", rule.SourceComponent)
+	assert.Equal(t, "Node", rule.InvolvedObjectKind)
+	assert.Equal(t, "^Deleting node [a-z]{1,3},x", rule.MessageRegex)
+	assert.Equal(t, NodeFieldName, rule.NodeField)
+
+	_, err = ParseRule("unknown=value,reason=DeletingNode")
+	assert.Error(t, err)
+}
+
+func TestRuleSetMatch(t *testing.T) {
+	rules, err := NewRuleSet(nil)
+	assert.NoError(t, err)
+
+	event := &v1.Event{
+		Reason:         RemovingNodeReason,
+		Source:         v1.EventSource{Component: NodeControllerComponent},
+		InvolvedObject: v1.ObjectReference{Kind: NodeKind, Name: "node-1", UID: "node-uid"},
+	}
+	nodeName, nodeUID, matched := rules.Match(event)
+	assert.True(t, matched)
+	assert.Equal(t, "node-1", nodeName)
+	assert.EqualValues(t, "node-uid", nodeUID)
+
+	event.Source.Component = "kubelet"
+	_, _, matched = rules.Match(event)
+	assert.False(t, matched)
+
+	rules, err = NewRuleSet([]Rule{{Reason: "Terminating", MessageRegex: "^Deleting", NodeField: NodeFieldFieldPath}})
+	assert.NoError(t, err)
+
+	event = &v1.Event{
+		Reason:         "Terminating",
+		Message:        "Deleting node",
+		InvolvedObject: v1.ObjectReference{Kind: "NodeClaim", Name: "claim-1", FieldPath: "node-2"},
+	}
+	nodeName, nodeUID, matched = rules.Match(event)
+	assert.True(t, matched)
+	assert.Equal(t, "node-2", nodeName)
+	assert.Empty(t, nodeUID)
+
+	_, err = NewRuleSet([]Rule{{Reason: "Terminating", NodeField: "labels"}})
+	assert.Error(t, err)
+}
+
+func TestFieldSelector(t *testing.T) {
+	rules, err := NewRuleSet(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "involvedObject.kind=Node,reason=RemovingNode,source=node-controller", rules.FieldSelector().String())
+
+	rules, err = NewRuleSet([]Rule{
+		{Reason: RemovingNodeReason, SourceComponent: NodeControllerComponent, InvolvedObjectKind: NodeKind},
+		{Reason: "DeletingNode", SourceComponent: "cloud-node-lifecycle-controller", InvolvedObjectKind: NodeKind},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "involvedObject.kind=Node", rules.FieldSelector().String())
+
+	rules, err = NewRuleSet([]Rule{{Reason: "Terminating"}, {Reason: "Terminating", InvolvedObjectKind: NodeKind}})
+	assert.NoError(t, err)
+	assert.Equal(t, "reason=Terminating", rules.FieldSelector().String())
+}
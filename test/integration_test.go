@@ -1,14 +1,17 @@
 package test
 
 import (
-	"github.com/AppsFlyer/local-pvc-releaser/test/objects"
 	"time"
 
+	releaserv1alpha1 "github.com/AppsFlyer/local-pvc-releaser/api/v1alpha1"
+	"github.com/AppsFlyer/local-pvc-releaser/test/objects"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/pkg/errors"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 var _ = Describe("Successful PVC Release", func() {
@@ -237,3 +240,303 @@ var _ = Describe("Ignoring PVC cleanup", func() {
 		})
 	})
 })
+
+var _ = Describe("Successful PVC Release on node deletion", func() {
+
+	// Define utility constants for object names and testing timeouts/durations and intervals.
+	const (
+		finalizerProtectionName = "kubernetes.io/pvc-protection"
+		pvcName                 = "pvc-test"
+		pvName                  = "test-pv"
+		nodeName                = "node-2"
+		storageClassName        = "local-storage"
+
+		timeout  = time.Second * 60
+		interval = time.Millisecond * 1000
+	)
+	AfterEach(func() {
+		objects.Helper().PersistentVolumeClaim().DeleteAll(ctx, k8sClient)
+		objects.Helper().PersistentVolume().DeleteAll(ctx, k8sClient)
+		objects.Helper().Event().DeleteAll(ctx, k8sClient)
+		objects.Helper().Node().Delete(ctx, k8sClient, nodeName)
+	})
+	Context("When a node object is deleted without a node-termination event", func() {
+		It("Should delete the related pvc", func() {
+			By("By Creating Node, PVC and PV objects")
+			node := objects.Helper().Node().Create(nodeName)
+			Expect(k8sClient.Create(ctx, node)).Should(Succeed())
+
+			pv := objects.Helper().PersistentVolume().Create(pvName, nodeName, storageClassName)
+			Expect(k8sClient.Create(ctx, pv)).Should(Succeed())
+
+			pvcAnnotations := map[string]string{
+				"appsflyer.com/local-pvc-releaser":   "enabled",
+				"volume.kubernetes.io/selected-node": nodeName,
+			}
+			pvc := objects.Helper().PersistentVolumeClaim().Create(pvcName, pvName, storageClassName, pvcAnnotations)
+			Expect(k8sClient.Create(ctx, pvc)).Should(Succeed())
+
+			fetchedPvc := &v1.PersistentVolumeClaim{}
+			Eventually(func() error {
+				return k8sClient.Get(ctx, types.NamespacedName{Name: pvc.Name, Namespace: pvc.Namespace}, fetchedPvc)
+			}, timeout, interval).Should(Succeed())
+
+			Expect(objects.Helper().PersistentVolumeClaim().RemoveProtectionFinalizer(ctx, k8sClient, fetchedPvc, finalizerProtectionName)).Should(Succeed())
+
+			By("By Deleting the Node object related to the PVC and PV")
+			objects.Helper().Node().Delete(ctx, k8sClient, nodeName)
+
+			allPvcList := &v1.PersistentVolumeClaimList{}
+			Eventually(func() error {
+				if err := k8sClient.List(ctx, allPvcList); err != nil {
+					return err
+				}
+				if len(allPvcList.Items) != 0 {
+					return errors.Errorf("expected amount of pvc to be 0, received %d", len(allPvcList.Items))
+				}
+				return nil
+			}, timeout, interval).Should(Succeed())
+		})
+	})
+})
+
+var _ = Describe("Release policy in dry-run", func() {
+
+	// Define utility constants for object names and testing timeouts/durations and intervals.
+	const (
+		finalizerProtectionName = "kubernetes.io/pvc-protection"
+		policyName              = "policy-test"
+		pvcName                 = "pvc-test"
+		pvName                  = "test-pv"
+		nodeName                = "node-1"
+		eventReason             = "RemovingNode"
+		storageClassName        = "local-storage"
+
+		timeout  = time.Second * 20
+		interval = time.Millisecond * 1000
+	)
+	AfterEach(func() {
+		objects.Helper().PersistentVolumeClaim().DeleteAll(ctx, k8sClient)
+		objects.Helper().PersistentVolume().DeleteAll(ctx, k8sClient)
+		objects.Helper().Event().DeleteAll(ctx, k8sClient)
+		objects.Helper().ReleasePolicy().DeleteAll(ctx, k8sClient)
+	})
+	Context("When Receiving event on node-termination", func() {
+		It("Should keep the pvc and report it on the policy status", func() {
+			By("By Creating a dry-run ReleasePolicy, a PV and PVC objects")
+			policy := objects.Helper().ReleasePolicy().Create(policyName, []string{storageClassName}, true)
+			Expect(k8sClient.Create(ctx, policy)).Should(Succeed())
+
+			pv := objects.Helper().PersistentVolume().Create(pvName, nodeName, storageClassName)
+			Expect(k8sClient.Create(ctx, pv)).Should(Succeed())
+
+			pvcAnnotations := map[string]string{
+				"volume.kubernetes.io/selected-node": nodeName,
+			}
+			pvc := objects.Helper().PersistentVolumeClaim().Create(pvcName, pvName, storageClassName, pvcAnnotations)
+			Expect(k8sClient.Create(ctx, pvc)).Should(Succeed())
+
+			fetchedPvc := &v1.PersistentVolumeClaim{}
+			Eventually(func() error {
+				return k8sClient.Get(ctx, types.NamespacedName{Name: pvc.Name, Namespace: pvc.Namespace}, fetchedPvc)
+			}, timeout, interval).Should(Succeed())
+
+			Expect(objects.Helper().PersistentVolumeClaim().RemoveProtectionFinalizer(ctx, k8sClient, fetchedPvc, finalizerProtectionName)).Should(Succeed())
+
+			By("By Creating Node-Termination event on the node related to the PVC and PV")
+			event := objects.Helper().Event().Create(nodeName, eventReason)
+			Expect(k8sClient.Create(ctx, event)).Should(Succeed())
+
+			fetchedPolicy := &releaserv1alpha1.ReleasePolicy{}
+			Eventually(func() error {
+				if err := k8sClient.Get(ctx, types.NamespacedName{Name: policyName}, fetchedPolicy); err != nil {
+					return err
+				}
+				if fetchedPolicy.Status.MatchedPVCs != 1 {
+					return errors.Errorf("expected policy to match 1 pvc, matched %d", fetchedPolicy.Status.MatchedPVCs)
+				}
+				return nil
+			}, timeout, interval).Should(Succeed())
+			Expect(fetchedPolicy.Status.ReleasedPVCs).To(BeEquivalentTo(0))
+
+			allPvcList := &v1.PersistentVolumeClaimList{}
+			Expect(k8sClient.List(ctx, allPvcList)).Should(Succeed())
+			Expect(len(allPvcList.Items)).To(BeEquivalentTo(1))
+		})
+	})
+})
+
+var _ = Describe("Successful PVC Release of a statically provisioned PV", func() {
+
+	// Define utility constants for object names and testing timeouts/durations and intervals.
+	const (
+		finalizerProtectionName = "kubernetes.io/pvc-protection"
+		pvcName                 = "pvc-test"
+		pvName                  = "test-pv"
+		nodeName                = "node-1"
+		eventReason             = "RemovingNode"
+		storageClassName        = "local-storage"
+
+		timeout  = time.Second * 60
+		interval = time.Millisecond * 1000
+	)
+	AfterEach(func() {
+		objects.Helper().PersistentVolumeClaim().DeleteAll(ctx, k8sClient)
+		objects.Helper().PersistentVolume().DeleteAll(ctx, k8sClient)
+		objects.Helper().Event().DeleteAll(ctx, k8sClient)
+	})
+	Context("When Receiving event on node-termination", func() {
+		It("Should delete the pvc bound to a pv pinned to the node", func() {
+			By("By Creating a PV pinned to the node and a PVC without the selected-node annotation")
+			pv := objects.Helper().PersistentVolume().Create(pvName, nodeName, storageClassName)
+			Expect(k8sClient.Create(ctx, pv)).Should(Succeed())
+
+			pvcAnnotations := map[string]string{
+				"appsflyer.com/local-pvc-releaser": "enabled",
+			}
+			pvc := objects.Helper().PersistentVolumeClaim().Create(pvcName, pvName, storageClassName, pvcAnnotations)
+			Expect(k8sClient.Create(ctx, pvc)).Should(Succeed())
+
+			// Waiting for the PV to be bound to the PVC
+			fetchedPv := &v1.PersistentVolume{}
+			Eventually(func() error {
+				if err := k8sClient.Get(ctx, types.NamespacedName{Name: pv.Name}, fetchedPv); err != nil {
+					return err
+				}
+				if fetchedPv.Spec.ClaimRef == nil {
+					return errors.New("PV is not bound to the PVC yet")
+				}
+				return nil
+			}, timeout, interval).Should(Succeed())
+
+			fetchedPvc := &v1.PersistentVolumeClaim{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: pvc.Name, Namespace: pvc.Namespace}, fetchedPvc)).Should(Succeed())
+			Expect(objects.Helper().PersistentVolumeClaim().RemoveProtectionFinalizer(ctx, k8sClient, fetchedPvc, finalizerProtectionName)).Should(Succeed())
+
+			By("By Creating Node-Termination event on the node related to the PV")
+			event := objects.Helper().Event().Create(nodeName, eventReason)
+			Expect(k8sClient.Create(ctx, event)).Should(Succeed())
+
+			allPvcList := &v1.PersistentVolumeClaimList{}
+			Eventually(func() error {
+				if err := k8sClient.List(ctx, allPvcList); err != nil {
+					return err
+				}
+				if len(allPvcList.Items) != 0 {
+					return errors.Errorf("expected amount of pvc to be 0, received %d", len(allPvcList.Items))
+				}
+				return nil
+			}, timeout, interval).Should(Succeed())
+		})
+	})
+})
+
+var _ = Describe("Force deleting pods stuck on the removed node", func() {
+
+	// Define utility constants for object names and testing timeouts/durations and intervals.
+	const (
+		pvcName          = "pvc-test"
+		pvName           = "test-pv"
+		podName          = "pod-test"
+		nodeName         = "node-3"
+		eventReason      = "RemovingNode"
+		storageClassName = "local-storage"
+
+		timeout  = time.Second * 60
+		interval = time.Millisecond * 1000
+	)
+	AfterEach(func() {
+		objects.Helper().Pod().DeleteAll(ctx, k8sClient)
+		objects.Helper().PersistentVolumeClaim().DeleteAll(ctx, k8sClient)
+		objects.Helper().PersistentVolume().DeleteAll(ctx, k8sClient)
+		objects.Helper().Event().DeleteAll(ctx, k8sClient)
+	})
+	Context("When Receiving event on node-termination", func() {
+		It("Should force delete the pod so the pvc protection does not block the release", func() {
+			By("By Creating PV, PVC and a pod referencing the PVC on the removed node")
+			pv := objects.Helper().PersistentVolume().Create(pvName, nodeName, storageClassName)
+			Expect(k8sClient.Create(ctx, pv)).Should(Succeed())
+
+			pvcAnnotations := map[string]string{
+				"appsflyer.com/local-pvc-releaser":   "enabled",
+				"volume.kubernetes.io/selected-node": nodeName,
+			}
+			pvc := objects.Helper().PersistentVolumeClaim().Create(pvcName, pvName, storageClassName, pvcAnnotations)
+			Expect(k8sClient.Create(ctx, pvc)).Should(Succeed())
+
+			pod := objects.Helper().Pod().Create(podName, nodeName, pvcName)
+			Expect(k8sClient.Create(ctx, pod)).Should(Succeed())
+
+			By("By Creating Node-Termination event on the node related to the PVC, PV and pod")
+			event := objects.Helper().Event().Create(nodeName, eventReason)
+			Expect(k8sClient.Create(ctx, event)).Should(Succeed())
+
+			Eventually(func() error {
+				fetchedPod := &v1.Pod{}
+				err := k8sClient.Get(ctx, types.NamespacedName{Name: podName, Namespace: pod.Namespace}, fetchedPod)
+				if err == nil {
+					return errors.New("pod still exists")
+				}
+				return client.IgnoreNotFound(err)
+			}, timeout, interval).Should(Succeed())
+
+			allPvcList := &v1.PersistentVolumeClaimList{}
+			Eventually(func() error {
+				if err := k8sClient.List(ctx, allPvcList); err != nil {
+					return err
+				}
+				if len(allPvcList.Items) != 0 {
+					return errors.Errorf("expected amount of pvc to be 0, received %d", len(allPvcList.Items))
+				}
+				return nil
+			}, timeout, interval).Should(Succeed())
+		})
+	})
+})
+
+var _ = Describe("Refusing the release of an existing node", func() {
+
+	// Define utility constants for object names and testing timeouts/durations and intervals.
+	const (
+		pvcName          = "pvc-test"
+		pvName           = "test-pv"
+		nodeName         = "node-4"
+		eventReason      = "RemovingNode"
+		storageClassName = "local-storage"
+
+		timeout  = time.Second * 20
+		interval = time.Millisecond * 1000
+	)
+	AfterEach(func() {
+		objects.Helper().PersistentVolumeClaim().DeleteAll(ctx, k8sClient)
+		objects.Helper().PersistentVolume().DeleteAll(ctx, k8sClient)
+		objects.Helper().Event().DeleteAll(ctx, k8sClient)
+		objects.Helper().Node().Delete(ctx, k8sClient, nodeName)
+	})
+	Context("When Receiving event on node-termination of a node that still exists", func() {
+		It("Should keep the related pvc", func() {
+			By("By Creating the Node, PVC and PV objects")
+			node := objects.Helper().Node().Create(nodeName)
+			Expect(k8sClient.Create(ctx, node)).Should(Succeed())
+
+			pv := objects.Helper().PersistentVolume().Create(pvName, nodeName, storageClassName)
+			Expect(k8sClient.Create(ctx, pv)).Should(Succeed())
+
+			pvcAnnotations := map[string]string{
+				"appsflyer.com/local-pvc-releaser":   "enabled",
+				"volume.kubernetes.io/selected-node": nodeName,
+			}
+			pvc := objects.Helper().PersistentVolumeClaim().Create(pvcName, pvName, storageClassName, pvcAnnotations)
+			Expect(k8sClient.Create(ctx, pvc)).Should(Succeed())
+
+			By("By Creating Node-Termination event carrying the UID of the existing node")
+			event := objects.Helper().Event().Create(nodeName, eventReason)
+			event.InvolvedObject.UID = node.UID
+			Expect(k8sClient.Create(ctx, event)).Should(Succeed())
+
+			Consistently(func() error {
+				return k8sClient.Get(ctx, types.NamespacedName{Name: pvc.Name, Namespace: pvc.Namespace}, &v1.PersistentVolumeClaim{})
+			}, timeout, interval).Should(Succeed())
+		})
+	})
+})
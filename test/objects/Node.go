@@ -1,15 +1,19 @@
 package objects
 
 import (
+	"context"
+
+	"github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 type Node interface {
-	Create() *corev1.Node
-	//DeleteAll(ctx context.Context, client client.Client)
-	//RemoveProtectionFinalizer(ctx context.Context, client client.Client, pvc *corev1.PersistentVolumeClaim, finalizerName string) error
+	Create(nodeName string) *corev1.Node
+	Delete(ctx context.Context, client client.Client, nodeName string)
 }
 
 type kubernetesNode struct {
@@ -19,14 +23,14 @@ func NewNode() Node {
 	return &kubernetesNode{}
 }
 
-func (kubernetesNode) Create() *corev1.Node {
+func (kubernetesNode) Create(nodeName string) *corev1.Node {
 	node := &corev1.Node{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "Node",
 			APIVersion: "v1",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:              "node-1",
+			Name:              nodeName,
 			CreationTimestamp: metav1.Now(),
 		},
 		Spec: corev1.NodeSpec{},
@@ -41,3 +45,12 @@ func (kubernetesNode) Create() *corev1.Node {
 
 	return node
 }
+
+func (kubernetesNode) Delete(ctx context.Context, client client.Client, nodeName string) {
+	node := &corev1.Node{}
+	if err := client.Get(ctx, types.NamespacedName{Name: nodeName}, node); err != nil {
+		return
+	}
+
+	gomega.Expect(client.Delete(ctx, node)).To(gomega.Succeed())
+}
@@ -0,0 +1,64 @@
+package objects
+
+import (
+	"context"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type Pod interface {
+	Create(name, nodeName, pvcName string) *corev1.Pod
+	DeleteAll(ctx context.Context, client client.Client)
+}
+
+type pod struct {
+}
+
+func NewPod() Pod {
+	return &pod{}
+}
+
+func (pod) Create(name, nodeName, pvcName string) *corev1.Pod {
+	return &corev1.Pod{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Pod",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+		},
+		Spec: corev1.PodSpec{
+			NodeName: nodeName,
+			Containers: []corev1.Container{
+				{
+					Name:  "app",
+					Image: "busybox",
+					VolumeMounts: []corev1.VolumeMount{
+						{Name: "data", MountPath: "/data"},
+					},
+				},
+			},
+			Volumes: []corev1.Volume{
+				{
+					Name: "data",
+					VolumeSource: corev1.VolumeSource{
+						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: pvcName},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (pod) DeleteAll(ctx context.Context, c client.Client) {
+	podList := &corev1.PodList{}
+	gomega.Expect(c.List(ctx, podList, client.InNamespace("default"))).To(gomega.Succeed())
+
+	for _, p := range podList.Items {
+		gomega.Expect(client.IgnoreNotFound(c.Delete(ctx, &p, client.GracePeriodSeconds(0)))).To(gomega.Succeed())
+	}
+}
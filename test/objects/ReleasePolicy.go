@@ -0,0 +1,49 @@
+package objects
+
+import (
+	"context"
+
+	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	releaserv1alpha1 "github.com/AppsFlyer/local-pvc-releaser/api/v1alpha1"
+)
+
+type ReleasePolicy interface {
+	Create(name string, storageClasses []string, dryRun bool) *releaserv1alpha1.ReleasePolicy
+	DeleteAll(ctx context.Context, client client.Client)
+}
+
+type releasePolicy struct{}
+
+func NewReleasePolicy() ReleasePolicy {
+	return &releasePolicy{}
+}
+
+func (*releasePolicy) Create(name string, storageClasses []string, dryRun bool) *releaserv1alpha1.ReleasePolicy {
+	policy := &releaserv1alpha1.ReleasePolicy{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "ReleasePolicy",
+			APIVersion: releaserv1alpha1.GroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Spec: releaserv1alpha1.ReleasePolicySpec{
+			StorageClasses: storageClasses,
+			DryRun:         dryRun,
+		},
+	}
+
+	return policy
+}
+
+func (*releasePolicy) DeleteAll(ctx context.Context, client client.Client) {
+	policyList := &releaserv1alpha1.ReleasePolicyList{}
+	gomega.Expect(client.List(ctx, policyList)).To(gomega.Succeed())
+
+	for _, policy := range policyList.Items {
+		gomega.Expect(client.Delete(ctx, &policy)).To(gomega.Succeed())
+	}
+}
@@ -4,14 +4,20 @@ type Utils interface {
 	PersistentVolumeClaim() PVC
 	PersistentVolume() PV
 	Event() Event
+	Node() Node
+	ReleasePolicy() ReleasePolicy
+	Pod() Pod
 }
 
 type utils struct {
 }
 
-func (*utils) PersistentVolumeClaim() PVC { return NewPVC() }
-func (*utils) PersistentVolume() PV       { return NewPV() }
-func (*utils) Event() Event               { return NewEvent() }
+func (*utils) PersistentVolumeClaim() PVC   { return NewPVC() }
+func (*utils) PersistentVolume() PV         { return NewPV() }
+func (*utils) Event() Event                 { return NewEvent() }
+func (*utils) Node() Node                   { return NewNode() }
+func (*utils) ReleasePolicy() ReleasePolicy { return NewReleasePolicy() }
+func (*utils) Pod() Pod                     { return NewPod() }
 
 func Helper() Utils {
 	return &utils{}
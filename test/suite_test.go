@@ -18,8 +18,10 @@ package test
 
 import (
 	"context"
+	"path/filepath"
 	"testing"
 
+	releaserv1alpha1 "github.com/AppsFlyer/local-pvc-releaser/api/v1alpha1"
 	"github.com/AppsFlyer/local-pvc-releaser/internal/controller"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -60,7 +62,9 @@ var _ = BeforeSuite(func() {
 	// TODO move to env var
 	LocalCluster := true
 	testEnv = &envtest.Environment{
-		UseExistingCluster: &LocalCluster,
+		UseExistingCluster:    &LocalCluster,
+		CRDDirectoryPaths:     []string{filepath.Join("..", "config", "crd", "bases")},
+		ErrorIfCRDPathMissing: true,
 	}
 
 	var err error
@@ -69,6 +73,9 @@ var _ = BeforeSuite(func() {
 	Expect(err).NotTo(HaveOccurred())
 	Expect(cfg).NotTo(BeNil())
 
+	err = releaserv1alpha1.AddToScheme(scheme.Scheme)
+	Expect(err).NotTo(HaveOccurred())
+
 	//+kubebuilder:scaffold:scheme
 
 	k8sManager, err := ctrl.NewManager(cfg, ctrl.Options{
@@ -88,6 +95,9 @@ var _ = BeforeSuite(func() {
 		PvcSelector:       true,
 		PvcAnoCustomKey:   "appsflyer.com/local-pvc-releaser",
 		PvcAnoCustomValue: "enabled",
+
+		PVNodeAffinityDiscovery: true,
+		ForceDeletePods:         true,
 	}).SetupWithManager(k8sManager)
 	Expect(err).ToNot(HaveOccurred())
 